@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+
+	"snippetbox.davc.io/internal/models"
+)
+
+// Size of the view chart's plot area in pixels, and the margin around it for
+// labels.
+const (
+	chartWidth  = 600
+	chartHeight = 150
+	chartMargin = 24
+)
+
+// Draw daily views as an SVG bar chart, with each bar's exact counts in its
+// tooltip. The chart is drawn on the server so the stats page works without
+// JavaScript.
+func viewChart(daily []*models.DailyViews) template.HTML {
+	if len(daily) == 0 {
+		return ""
+	}
+
+	most := 1
+	for _, d := range daily {
+		most = max(most, d.Views)
+	}
+
+	width := chartWidth + 2*chartMargin
+	height := chartHeight + 2*chartMargin
+	slot := float64(chartWidth) / float64(len(daily))
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, `<svg class='chart' xmlns='http://www.w3.org/2000/svg' viewBox='0 0 %d %d' role='img' aria-label='%d views over the last %d days'>`,
+		width, height, totalViews(daily), len(daily))
+
+	// Axis along the bottom, labelled with the first and last days, and the
+	// highest count at the top.
+	fmt.Fprintf(&b, `<line class='axis' x1='%d' y1='%d' x2='%d' y2='%d'/>`,
+		chartMargin, chartMargin+chartHeight, chartMargin+chartWidth, chartMargin+chartHeight)
+	fmt.Fprintf(&b, `<text x='%d' y='%d'>%s</text>`,
+		chartMargin, height-6, daily[0].Day.Format("02 Jan"))
+	fmt.Fprintf(&b, `<text x='%d' y='%d' text-anchor='end'>%s</text>`,
+		chartMargin+chartWidth, height-6, daily[len(daily)-1].Day.Format("02 Jan"))
+	fmt.Fprintf(&b, `<text x='%d' y='%d' text-anchor='end'>%d</text>`,
+		chartMargin-4, chartMargin+4, most)
+
+	for i, d := range daily {
+		h := float64(d.Views) / float64(most) * chartHeight
+		fmt.Fprintf(&b, `<rect class='bar' x='%.1f' y='%.1f' width='%.1f' height='%.1f'><title>%s: %s, %s</title></rect>`,
+			chartMargin+float64(i)*slot+1, chartMargin+chartHeight-h, slot-2, h,
+			d.Day.Format("02 Jan 2006"), plural(d.Views, "view"), plural(d.Visitors, "visitor"))
+	}
+
+	b.WriteString(`</svg>`)
+
+	return template.HTML(b.String())
+}
+
+// Sum of the views on each day.
+func totalViews(daily []*models.DailyViews) int {
+	total := 0
+	for _, d := range daily {
+		total += d.Views
+	}
+	return total
+}
+
+// Format a count of things, such as "1 view" or "2 views".
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
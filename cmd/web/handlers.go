@@ -1,13 +1,22 @@
 package main
 
 import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"snippetbox.davc.io/internal/diff"
+	"snippetbox.davc.io/internal/highlight"
 	"snippetbox.davc.io/internal/models"
 	"snippetbox.davc.io/internal/models/validator"
+	"snippetbox.davc.io/internal/query"
 
 	"github.com/julienschmidt/httprouter"
 )
@@ -18,14 +27,26 @@ func (self *application) about(w http.ResponseWriter, r *http.Request) {
 }
 
 func (self *application) home(w http.ResponseWriter, r *http.Request) {
-	snippets, err := self.snippets.Latest()
+	page, err := self.snippets.Latest(r.URL.Query().Get("cursor"), self.readPageSize(r))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCursor) {
+			self.clientError(w, http.StatusBadRequest)
+		} else {
+			self.serverError(w, err)
+		}
+		return
+	}
+
+	tags, err := self.tags.Cloud(30)
 	if err != nil {
 		self.serverError(w, err)
 		return
 	}
 
 	data := self.newTemplateData(r)
-	data.Snippets = snippets
+	data.Snippets = page.Snippets
+	data.Tags = tags
+	data.PrevPage, data.NextPage = pageLinks(r, page)
 
 	self.render(w, http.StatusOK, "home.html", data)
 }
@@ -43,23 +64,52 @@ func (self *application) accountView(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	page, err := self.snippets.ByOwner(userID, r.URL.Query().Get("cursor"), self.readPageSize(r))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCursor) {
+			self.clientError(w, http.StatusBadRequest)
+		} else {
+			self.serverError(w, err)
+		}
+		return
+	}
+
 	data := self.newTemplateData(r)
 	data.User = user
+	data.Snippets = page.Snippets
+	data.PrevPage, data.NextPage = pageLinks(r, page)
 
 	self.render(w, http.StatusOK, "account.html", data)
 }
 
 func (self *application) snippetView(w http.ResponseWriter, r *http.Request) {
-	// Retrieve named parameters from request context.
-	params := httprouter.ParamsFromContext(r.Context())
+	snippet, ok := self.readSnippetParam(w, r)
+	if !ok {
+		return
+	}
 
-	id, err := strconv.Atoi(params.ByName("id"))
-	if err != nil || id < 1 {
-		self.notFound(w)
+	if !self.consumeView(w, snippet) {
+		return
+	}
+
+	self.recordView(r, snippet)
+
+	data, err := self.snippetViewData(r, snippet)
+	if err != nil {
+		self.serverError(w, err)
 		return
 	}
 
-	snippet, err := self.snippets.Get(id)
+	self.render(w, http.StatusOK, "view.html", data)
+}
+
+// Show a snippet on its own, for framing in other sites. There's no session
+// here, so snippets are found by slug as an anonymous viewer would, and ones
+// behind a password or a view limit are never shown.
+func (self *application) snippetEmbed(w http.ResponseWriter, r *http.Request) {
+	slug := httprouter.ParamsFromContext(r.Context()).ByName("id")
+
+	snippet, err := self.snippets.GetBySlug(slug, 0)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
 			self.notFound(w)
@@ -69,65 +119,1198 @@ func (self *application) snippetView(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if snippet.Protected || snippet.ViewsLeft != nil {
+		self.notFound(w)
+		return
+	}
+
+	self.viewRecorder.record(r, snippet.ID)
+
+	data := &templateData{
+		CurrentYear: time.Now().Year(),
+		Snippet:     snippet,
+	}
+
+	self.render(w, http.StatusOK, "embed.html", data)
+}
+
+// Gather what a snippet's page shows besides the snippet itself: how many
+// views it has left, its forks and its comments.
+func (self *application) snippetViewData(r *http.Request, snippet *models.Snippet) (*templateData, error) {
 	data := self.newTemplateData(r)
+
+	if snippet.ViewsLeft != nil {
+		switch *snippet.ViewsLeft {
+		case 0:
+			data.Flash = "This was the last view of this snippet. It can't be opened again."
+		case 1:
+			data.Flash = "This snippet can be viewed 1 more time."
+		default:
+			data.Flash = fmt.Sprintf("This snippet can be viewed %d more times.", *snippet.ViewsLeft)
+		}
+	}
+
 	data.Snippet = snippet
+	data.Form = commentForm{}
 
-	self.render(w, http.StatusOK, "view.html", data)
+	if data.IsAuthenticated {
+		starred, err := self.stars.Starred(data.AuthenticatedUserID, snippet.ID)
+		if err != nil {
+			return nil, err
+		}
+		data.Starred = starred
+	}
+
+	if snippet.Forks > 0 {
+		forks, err := self.snippets.Forks(snippet.ID, maxForksShown)
+		if err != nil {
+			return nil, err
+		}
+		data.Snippets = forks
+	}
+
+	if data.IsAuthenticated && snippet.ViewsLeft == nil {
+		collections, err := self.collections.ByOwner(data.AuthenticatedUserID)
+		if err != nil {
+			return nil, err
+		}
+		data.Collections = collections
+	}
+
+	// Each view of a view-limited snippet uses one up, so there's no
+	// discussing them.
+	if snippet.ViewsLeft == nil {
+		comments, err := self.comments.ForSnippet(snippet.ID)
+		if err != nil {
+			return nil, err
+		}
+		data.Comments = comments
+	}
+
+	return data, nil
 }
 
-// Show a snippet form
-func (self *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
+// Most forks listed on a snippet's page.
+const maxForksShown = 10
+
+// Copy a snippet into the current user's account.
+func (self *application) snippetForkPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := self.readSnippetParam(w, r)
+	if !ok {
+		return
+	}
+
+	// Forking would keep the content of a view-limited snippet around for
+	// good, and make a copy of a protected one without its password.
+	if snippet.ViewsLeft != nil || snippet.Protected {
+		self.notFound(w)
+		return
+	}
+
+	userID := self.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	slug, err := self.snippets.Fork(snippet.ID, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			self.notFound(w)
+		} else {
+			self.serverError(w, err)
+		}
+		return
+	}
+
+	self.sessionManager.Put(r.Context(), "flash", "Snippet successfully forked!")
+
+	http.Redirect(w, r, "/snippet/view/"+slug, http.StatusSeeOther)
+}
+
+// Star a snippet for the current user.
+func (self *application) snippetStarPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := self.readSnippetParam(w, r)
+	if !ok {
+		return
+	}
+
+	// The snippet's page is where a star leads back to, and opening it would
+	// use up a view.
+	if snippet.ViewsLeft != nil {
+		self.notFound(w)
+		return
+	}
+
+	userID := self.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	err := self.stars.Star(userID, snippet.ID)
+	if err != nil {
+		self.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/snippet/view/"+snippet.Slug, http.StatusSeeOther)
+}
+
+// Remove the current user's star from a snippet.
+func (self *application) snippetUnstarPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := self.readSnippetParam(w, r)
+	if !ok {
+		return
+	}
+
+	userID := self.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	err := self.stars.Unstar(userID, snippet.ID)
+	if err != nil {
+		self.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/snippet/view/"+snippet.Slug, http.StatusSeeOther)
+}
+
+type commentForm struct {
+	Body                string `form:"body"`
+	Line                string `form:"line"`
+	ParentID            int    `form:"parent_id"`
+	validator.Validator `form:"-"`
+}
+
+// Longest comment that can be posted, in characters.
+const maxCommentLength = 5000
+
+func checkComment(v *validator.Validator, body string) {
+	v.CheckField(validator.NotBlank(body), "body", "This field cannot be blank")
+	v.CheckField(validator.MaxChars(body, maxCommentLength), "body", fmt.Sprintf("This field cannot be more than %d characters long", maxCommentLength))
+}
+
+// Post a comment on a snippet, or a reply to one of its comments.
+func (self *application) snippetCommentPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := self.readSnippetParam(w, r)
+	if !ok {
+		return
+	}
+
+	if snippet.ViewsLeft != nil {
+		self.notFound(w)
+		return
+	}
+
+	var form commentForm
+
+	err := self.decodePostForm(r, &form)
+	if err != nil {
+		self.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.Line = strings.TrimSpace(strings.TrimPrefix(form.Line, "#"))
+
+	checkComment(&form.Validator, form.Body)
+	form.CheckField(form.Line == "" || lineExists(snippet, form.Line), "line", "This field must be a line of the snippet, such as L12")
+
+	if !form.Valid() {
+		data, err := self.snippetViewData(r, snippet)
+		if err != nil {
+			self.serverError(w, err)
+			return
+		}
+
+		data.Form = form
+		self.render(w, http.StatusUnprocessableEntity, "view.html", data)
+		return
+	}
+
+	userID := self.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	id, err := self.comments.Insert(snippet.ID, userID, form.ParentID, form.Line, form.Body)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			self.notFound(w)
+		} else {
+			self.serverError(w, err)
+		}
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s#comment-%d", snippet.Slug, id), http.StatusSeeOther)
+}
+
+type commentEditForm struct {
+	Body                string `form:"body"`
+	validator.Validator `form:"-"`
+}
+
+// Look up the comment named by the id URL parameter, writing a not found
+// response unless the current user wrote it.
+func (self *application) readOwnComment(w http.ResponseWriter, r *http.Request) (*models.Comment, bool) {
+	id, err := readIDParam(r)
+	if err != nil {
+		self.notFound(w)
+		return nil, false
+	}
+
+	comment, err := self.comments.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			self.notFound(w)
+		} else {
+			self.serverError(w, err)
+		}
+		return nil, false
+	}
+
+	if comment.UserID != self.sessionManager.GetInt(r.Context(), "authenticatedUserID") {
+		self.notFound(w)
+		return nil, false
+	}
+
+	return comment, true
+}
+
+// Show the edit form for a comment written by the current user.
+func (self *application) commentEdit(w http.ResponseWriter, r *http.Request) {
+	comment, ok := self.readOwnComment(w, r)
+	if !ok {
+		return
+	}
+
 	data := self.newTemplateData(r)
+	data.Comment = comment
+	data.Form = commentEditForm{Body: comment.Body}
 
-	data.Form = snippetCreateForm{
-		Expires: 365,
+	self.render(w, http.StatusOK, "comment.html", data)
+}
+
+func (self *application) commentEditPost(w http.ResponseWriter, r *http.Request) {
+	comment, ok := self.readOwnComment(w, r)
+	if !ok {
+		return
 	}
 
-	self.render(w, http.StatusOK, "create.html", data)
+	var form commentEditForm
+
+	err := self.decodePostForm(r, &form)
+	if err != nil {
+		self.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	checkComment(&form.Validator, form.Body)
+
+	if !form.Valid() {
+		data := self.newTemplateData(r)
+		data.Comment = comment
+		data.Form = form
+		self.render(w, http.StatusUnprocessableEntity, "comment.html", data)
+		return
+	}
+
+	err = self.comments.Update(comment.ID, comment.UserID, form.Body)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			self.notFound(w)
+		} else {
+			self.serverError(w, err)
+		}
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s#comment-%d", comment.SnippetSlug, comment.ID), http.StatusSeeOther)
 }
 
-// Struct tags map HTML form values to struct fields.
-// `form:"-"`  to ignore a field during decoding.
-type snippetCreateForm struct {
-	Title               string `form:"title"`
-	Content             string `form:"content"`
-	Expires             int    `form:"expires"`
+func (self *application) commentDeletePost(w http.ResponseWriter, r *http.Request) {
+	comment, ok := self.readOwnComment(w, r)
+	if !ok {
+		return
+	}
+
+	err := self.comments.Delete(comment.ID, comment.UserID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			self.notFound(w)
+		} else {
+			self.serverError(w, err)
+		}
+		return
+	}
+
+	self.sessionManager.Put(r.Context(), "flash", "Comment deleted.")
+
+	http.Redirect(w, r, "/snippet/view/"+comment.SnippetSlug+"#comments", http.StatusSeeOther)
+}
+
+// Serve a snippet's content as plain text for scripts and the command line.
+func (self *application) snippetRaw(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := self.readSnippetParam(w, r)
+	if !ok || !self.consumeView(w, snippet) {
+		return
+	}
+
+	self.recordView(r, snippet)
+	writeRaw(w, snippet.Content)
+}
+
+// Serve one file of a snippet, looked up by its filename, as plain text.
+func (self *application) snippetRawFile(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := self.readSnippetParam(w, r)
+	if !ok {
+		return
+	}
+
+	content, ok := fileContent(snippet, httprouter.ParamsFromContext(r.Context()).ByName("file"))
+	if !ok {
+		self.notFound(w)
+		return
+	}
+
+	if !self.consumeView(w, snippet) {
+		return
+	}
+
+	self.recordView(r, snippet)
+	writeRaw(w, content)
+}
+
+// Serve the first file of a snippet as a file named after it, or after the
+// snippet's title and language when it has no name.
+func (self *application) snippetDownload(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := self.readSnippetParam(w, r)
+	if !ok || !self.consumeView(w, snippet) {
+		return
+	}
+
+	self.recordView(r, snippet)
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, downloadFilename(snippet)))
+	writeRaw(w, snippet.Content)
+}
+
+// How many days the stats page covers.
+const statsDays = 30
+
+// Most referrers listed on the stats page.
+const maxReferrersShown = 10
+
+// Show the owner of a snippet how often it was viewed each day and which
+// sites linked to it.
+func (self *application) snippetStats(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := self.findSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	if !self.isOwner(r, snippet) {
+		self.notFound(w)
+		return
+	}
+
+	daily, err := self.views.Daily(snippet.ID, statsDays)
+	if err != nil {
+		self.serverError(w, err)
+		return
+	}
+
+	referrers, err := self.views.Referrers(snippet.ID, statsDays, maxReferrersShown)
+	if err != nil {
+		self.serverError(w, err)
+		return
+	}
+
+	data := self.newTemplateData(r)
+	data.Snippet = snippet
+	data.DailyViews = daily
+	data.Referrers = referrers
+
+	self.render(w, http.StatusOK, "stats.html", data)
+}
+
+// Show the owner of a view-limited snippet its link without using up a view.
+func (self *application) snippetShare(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := self.findSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	if !self.isOwner(r, snippet) {
+		self.notFound(w)
+		return
+	}
+
+	data := self.newTemplateData(r)
+	data.Snippet = snippet
+	data.ShareURL = "https://" + r.Host + "/snippet/view/" + snippet.Slug
+
+	self.render(w, http.StatusOK, "share.html", data)
+}
+
+type snippetUnlockForm struct {
+	Password            string `form:"password"`
+	validator.Validator `form:"-"`
+}
+
+// Check the password of a protected snippet and remember in the session that
+// it was unlocked.
+func (self *application) snippetUnlockPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := self.findSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	if !self.isLocked(r, snippet) {
+		http.Redirect(w, r, "/snippet/view/"+snippet.Slug, http.StatusSeeOther)
+		return
+	}
+
+	var form snippetUnlockForm
+
+	err := self.decodePostForm(r, &form)
+	if err != nil {
+		self.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	data := self.newTemplateData(r)
+	data.Snippet = snippet
+
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+
+	if form.Valid() {
+		// Refuse further attempts, right or wrong, once a snippet has had
+		// too many failures.
+		if !self.unlockLimiter.reserve(snippet.ID) {
+			form.AddNonFieldError("Too many incorrect passwords. Please try again later.")
+			data.Form = form
+			self.render(w, http.StatusTooManyRequests, "unlock.html", data)
+			return
+		}
+
+		err = self.snippets.CheckPassword(snippet.ID, form.Password)
+		if err != nil {
+			if !errors.Is(err, models.ErrInvalidCredentials) {
+				self.unlockLimiter.refund(snippet.ID)
+				self.serverError(w, err)
+				return
+			}
+
+			form.AddFieldError("password", "Incorrect password")
+		} else {
+			self.unlockLimiter.refund(snippet.ID)
+		}
+	}
+
+	if !form.Valid() {
+		data.Form = form
+		self.render(w, http.StatusUnprocessableEntity, "unlock.html", data)
+		return
+	}
+
+	self.sessionManager.Put(r.Context(), unlockedKey(snippet.ID), true)
+
+	http.Redirect(w, r, "/snippet/view/"+snippet.Slug, http.StatusSeeOther)
+}
+
+// Show a snippet form
+func (self *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
+	data := self.newTemplateData(r)
+
+	data.Form = snippetCreateForm{
+		Language:    highlight.Auto,
+		Visibility:  models.VisibilityPublic,
+		Expiry:      "after",
+		ExpiresIn:   365,
+		ExpiresUnit: "days",
+	}
+
+	self.render(w, http.StatusOK, "create.html", data)
+}
+
+// Struct tags map HTML form values to struct fields.
+// `form:"-"`  to ignore a field during decoding.
+type snippetCreateForm struct {
+	Title               string            `form:"title"`
+	Filename            string            `form:"filename"`
+	Content             string            `form:"content"`
+	Language            string            `form:"language"`
+	Files               []snippetFileForm `form:"files"`
+	Visibility          models.Visibility `form:"visibility"`
+	Password            string            `form:"password"`
+	MaxViews            int               `form:"max_views"`
+	Expiry              string            `form:"expiry"`
+	ExpiresIn           int               `form:"expires_in"`
+	ExpiresUnit         string            `form:"expires_unit"`
+	ExpiresAt           string            `form:"expires_at"`
+	Tags                string            `form:"tags"`
+	validator.Validator `form:"-"`
+}
+
+// View limits a snippet form may submit, where 0 means unlimited.
+var viewLimits = []int{0, 1, 2, 5, 10, 100}
+
+// Visibilities a snippet form may submit.
+var visibilities = []models.Visibility{models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate}
+
+// Most tags a snippet can carry.
+const maxTags = 10
+
+// Units a snippet form may give its expiry in.
+var expiryUnits = map[string]time.Duration{
+	"minutes": time.Minute,
+	"hours":   time.Hour,
+	"days":    24 * time.Hour,
+	"weeks":   7 * 24 * time.Hour,
+}
+
+// Layout of the datetime-local input for a fixed expiry time, which is taken
+// to be UTC like the times shown on the site.
+const expiresAtLayout = "2006-01-02T15:04"
+
+// Latest a snippet may expire when the deployment sets no maximum.
+const longestExpiry = 100 * 365 * 24 * time.Hour
+
+// Work out when a new snippet should expire from the create form, recording
+// an error when it falls outside the deployment's limits. Snippets may only
+// never expire when there's no maximum.
+func (self *application) checkExpiry(form *snippetCreateForm) models.Expiry {
+	var expiry models.Expiry
+	var d time.Duration
+
+	switch form.Expiry {
+	case "after":
+		unit, ok := expiryUnits[form.ExpiresUnit]
+		if !ok {
+			form.AddFieldError("expires", "This field must be in minutes, hours, days or weeks")
+			return expiry
+		}
+		if form.ExpiresIn < 1 || form.ExpiresIn > int(longestExpiry/unit) {
+			form.AddFieldError("expires", "This field must be a whole number within 100 years")
+			return expiry
+		}
+		d = time.Duration(form.ExpiresIn) * unit
+		expiry.Minutes = int(d / time.Minute)
+	case "on":
+		at, err := time.Parse(expiresAtLayout, form.ExpiresAt)
+		if err != nil {
+			form.AddFieldError("expires", "This field must be a date and time")
+			return expiry
+		}
+		d = time.Until(at)
+		expiry.At = at
+	case "never":
+		if self.maxExpiry > 0 {
+			form.AddFieldError("expires", fmt.Sprintf("Snippets must expire within %s", formatDuration(self.maxExpiry)))
+		}
+		return expiry
+	default:
+		form.AddFieldError("expires", "This field must equal after, on or never")
+		return expiry
+	}
+
+	limit := self.maxExpiry
+	if limit == 0 {
+		limit = longestExpiry
+	}
+
+	form.CheckField(d >= self.minExpiry, "expires", fmt.Sprintf("Snippets cannot expire sooner than %s from now", formatDuration(self.minExpiry)))
+	form.CheckField(d <= limit, "expires", fmt.Sprintf("Snippets cannot expire later than %s from now", formatDuration(limit)))
+
+	return expiry
+}
+
+// A file after the first on a snippet form.
+type snippetFileForm struct {
+	Filename string `form:"filename"`
+	Content  string `form:"content"`
+	Language string `form:"language"`
+}
+
+// Most files a snippet can have.
+const maxFiles = 10
+
+// Most characters in any one file of a snippet.
+const maxContentLength = 512 << 10
+
+// The error reported for a file longer than maxContentLength.
+var contentTooLong = fmt.Sprintf("Files cannot be more than %dK characters long", maxContentLength>>10)
+
+// Check the filename of a snippet's first file, which is optional, and the
+// files after it, which all need a name. Errors about a file after the first
+// are recorded under "files.N" for its index N.
+func checkFiles(v *validator.Validator, filename string, files []snippetFileForm) {
+	v.CheckField(len(files) < maxFiles, "files", fmt.Sprintf("A snippet cannot have more than %d files", maxFiles))
+
+	if filename != "" {
+		checkFilename(v, "filename", filename)
+	}
+
+	seen := map[string]bool{filename: true}
+
+	for i, f := range files {
+		key := fmt.Sprintf("files.%d", i)
+
+		v.CheckField(validator.NotBlank(f.Filename), key, "Every file after the first needs a filename")
+		checkFilename(v, key, f.Filename)
+		v.CheckField(!seen[f.Filename], key, "Each file needs a different filename")
+		v.CheckField(validator.NotBlank(f.Content), key, "Files cannot be blank")
+		v.CheckField(validator.MaxChars(f.Content, maxContentLength), key, contentTooLong)
+		v.CheckField(validator.PermittedValue(f.Language, highlight.Names()...), key, "Files must be in one of the listed languages")
+
+		seen[f.Filename] = true
+	}
+}
+
+func checkFilename(v *validator.Validator, key string, filename string) {
+	v.CheckField(validator.MaxChars(filename, 100), key, "Filenames cannot be more than 100 characters long")
+	v.CheckField(validator.Matches(filename, validator.FilenameRX) && filename != "." && filename != "..", key,
+		`Filenames can only contain letters, digits, ".", "_" and "-"`)
+}
+
+// Convert the files after the first on a snippet form for the model.
+func modelFiles(files []snippetFileForm) []*models.File {
+	result := make([]*models.File, len(files))
+	for i, f := range files {
+		result[i] = &models.File{Filename: f.Filename, Language: f.Language, Content: f.Content}
+	}
+	return result
+}
+
+func checkTags(v *validator.Validator, tags []string) {
+	v.CheckField(len(tags) <= maxTags, "tags", fmt.Sprintf("This field cannot have more than %d tags", maxTags))
+
+	for _, tag := range tags {
+		v.CheckField(validator.MaxChars(tag, 30), "tags", "Tags cannot be more than 30 characters long")
+		v.CheckField(validator.Matches(tag, validator.TagRX), "tags", `Tags can only contain letters, digits, ".", "+" and "-"`)
+	}
+}
+
+// Create snippet
+func (self *application) snippetCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form snippetCreateForm
+
+	// Bodies over the limit never get here, as they fail the CSRF check.
+	err := self.decodeMultipartForm(r, &form)
+	if err != nil {
+		self.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// Uploads fill in the form before it's checked, so the title and
+	// languages can default from their filenames.
+	err = readUploads(&form, r)
+	if err != nil {
+		self.serverError(w, err)
+		return
+	}
+
+	// Because the Validator type is embedded by the snippetCreateForm struct,
+	// we can call CheckField() directly on it.
+	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
+	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Content, maxContentLength), "content", contentTooLong)
+	form.CheckField(validator.PermittedValue(form.Language, highlight.Names()...), "language", "This field must be one of the listed languages")
+	form.CheckField(validator.PermittedValue(form.Visibility, visibilities...), "visibility", "This field must equal public, unlisted or private")
+	form.CheckField(validator.PermittedValue(form.MaxViews, viewLimits...), "max_views", "This field must be one of the listed view limits")
+
+	// The password is optional, but bcrypt ignores anything past 72 bytes.
+	if form.Password != "" {
+		form.CheckField(validator.MinChars(form.Password, 8), "password", "This field must be at least 8 characters long")
+		form.CheckField(len(form.Password) <= 72, "password", "This field cannot be more than 72 bytes long")
+	}
+
+	expiry := self.checkExpiry(&form)
+
+	checkFiles(&form.Validator, form.Filename, form.Files)
+
+	tags := splitTags(form.Tags)
+	checkTags(&form.Validator, tags)
+
+	if !form.Valid() {
+		data := self.newTemplateData(r)
+		data.Form = form
+		self.render(w, http.StatusUnprocessableEntity, "create.html", data)
+		return
+	}
+
+	userID := self.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	slug, err := self.snippets.Insert(userID, form.Title, form.Filename, form.Content, form.Language, modelFiles(form.Files), form.Visibility, form.Password, form.MaxViews, expiry, tags)
+	if err != nil {
+		self.serverError(w, err)
+		return
+	}
+
+	self.sessionManager.Put(r.Context(), "flash", "Snippet successfully created!")
+
+	// Opening a view-limited snippet uses up a view, so send its creator to
+	// the share page rather than straight to the snippet.
+	if form.MaxViews > 0 {
+		http.Redirect(w, r, "/snippet/share/"+slug, http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, r, "/snippet/view/"+slug, http.StatusSeeOther)
+}
+
+type snippetEditForm struct {
+	Title               string            `form:"title"`
+	Filename            string            `form:"filename"`
+	Content             string            `form:"content"`
+	Language            string            `form:"language"`
+	Files               []snippetFileForm `form:"files"`
+	Visibility          models.Visibility `form:"visibility"`
+	Tags                string            `form:"tags"`
+	validator.Validator `form:"-"`
+}
+
+// Show the edit form for a snippet owned by the current user.
+func (self *application) snippetEdit(w http.ResponseWriter, r *http.Request) {
+	id, err := readIDParam(r)
+	if err != nil {
+		self.notFound(w)
+		return
+	}
+
+	userID := self.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	snippet, err := self.snippets.Get(id, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			self.notFound(w)
+		} else {
+			self.serverError(w, err)
+		}
+		return
+	}
+
+	if snippet.UserID != userID {
+		self.notFound(w)
+		return
+	}
+
+	data := self.newTemplateData(r)
+	data.Snippet = snippet
+	files := make([]snippetFileForm, len(snippet.Files))
+	for i, f := range snippet.Files {
+		files[i] = snippetFileForm{Filename: f.Filename, Content: f.Content, Language: f.Language}
+	}
+
+	data.Form = snippetEditForm{
+		Title:      snippet.Title,
+		Filename:   snippet.Filename,
+		Content:    snippet.Content,
+		Language:   snippet.Language,
+		Files:      files,
+		Visibility: snippet.Visibility,
+		Tags:       strings.Join(snippet.Tags, ", "),
+	}
+
+	self.render(w, http.StatusOK, "edit.html", data)
+}
+
+func (self *application) snippetEditPost(w http.ResponseWriter, r *http.Request) {
+	id, err := readIDParam(r)
+	if err != nil {
+		self.notFound(w)
+		return
+	}
+
+	userID := self.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	snippet, err := self.snippets.Get(id, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			self.notFound(w)
+		} else {
+			self.serverError(w, err)
+		}
+		return
+	}
+
+	if snippet.UserID != userID {
+		self.notFound(w)
+		return
+	}
+
+	var form snippetEditForm
+
+	err = self.decodePostForm(r, &form)
+	if err != nil {
+		self.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
+	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Content, maxContentLength), "content", contentTooLong)
+	form.CheckField(validator.PermittedValue(form.Language, highlight.Names()...), "language", "This field must be one of the listed languages")
+	form.CheckField(validator.PermittedValue(form.Visibility, visibilities...), "visibility", "This field must equal public, unlisted or private")
+
+	checkFiles(&form.Validator, form.Filename, form.Files)
+
+	tags := splitTags(form.Tags)
+	checkTags(&form.Validator, tags)
+
+	if !form.Valid() {
+		data := self.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		self.render(w, http.StatusUnprocessableEntity, "edit.html", data)
+		return
+	}
+
+	err = self.snippets.Update(id, userID, form.Title, form.Filename, form.Content, form.Language, modelFiles(form.Files), form.Visibility, tags)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			self.notFound(w)
+		} else {
+			self.serverError(w, err)
+		}
+		return
+	}
+
+	self.sessionManager.Put(r.Context(), "flash", "Snippet successfully updated!")
+
+	http.Redirect(w, r, "/snippet/view/"+snippet.Slug, http.StatusSeeOther)
+}
+
+// List every saved revision of a snippet.
+func (self *application) snippetHistory(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := self.readHistoryParam(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := self.snippets.Revisions(snippet.ID)
+	if err != nil {
+		self.serverError(w, err)
+		return
+	}
+
+	data := self.newTemplateData(r)
+	data.Snippet = snippet
+	data.Revisions = revisions
+
+	self.render(w, http.StatusOK, "history.html", data)
+}
+
+// Move a snippet to its owner's trash.
+func (self *application) snippetDeletePost(w http.ResponseWriter, r *http.Request) {
+	id, err := readIDParam(r)
+	if err != nil {
+		self.notFound(w)
+		return
+	}
+
+	userID := self.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	err = self.snippets.Delete(id, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			self.notFound(w)
+		} else {
+			self.serverError(w, err)
+		}
+		return
+	}
+
+	self.sessionManager.Put(r.Context(), "flash", "Snippet moved to trash.")
+
+	http.Redirect(w, r, "/account/trash", http.StatusSeeOther)
+}
+
+func (self *application) accountTrash(w http.ResponseWriter, r *http.Request) {
+	userID := self.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	snippets, err := self.snippets.Trash(userID)
+	if err != nil {
+		self.serverError(w, err)
+		return
+	}
+
+	data := self.newTemplateData(r)
+	data.Snippets = snippets
+
+	self.render(w, http.StatusOK, "trash.html", data)
+}
+
+// List the snippets the current user has starred.
+func (self *application) accountStars(w http.ResponseWriter, r *http.Request) {
+	userID := self.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	page, err := self.stars.ByUser(userID, r.URL.Query().Get("cursor"), self.readPageSize(r))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCursor) {
+			self.clientError(w, http.StatusBadRequest)
+		} else {
+			self.serverError(w, err)
+		}
+		return
+	}
+
+	data := self.newTemplateData(r)
+	data.Snippets = page.Snippets
+	data.PrevPage, data.NextPage = pageLinks(r, page)
+
+	self.render(w, http.StatusOK, "stars.html", data)
+}
+
+func (self *application) accountTrashRestorePost(w http.ResponseWriter, r *http.Request) {
+	id, err := readIDParam(r)
+	if err != nil {
+		self.notFound(w)
+		return
+	}
+
+	userID := self.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	err = self.snippets.Restore(id, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			self.notFound(w)
+		} else {
+			self.serverError(w, err)
+		}
+		return
+	}
+
+	self.sessionManager.Put(r.Context(), "flash", "Snippet restored!")
+
+	// The trash doesn't know slugs; the numeric URL redirects the owner.
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
+}
+
+func (self *application) accountTrashPurgePost(w http.ResponseWriter, r *http.Request) {
+	id, err := readIDParam(r)
+	if err != nil {
+		self.notFound(w)
+		return
+	}
+
+	userID := self.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	err = self.snippets.Purge(id, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			self.notFound(w)
+		} else {
+			self.serverError(w, err)
+		}
+		return
+	}
+
+	self.sessionManager.Put(r.Context(), "flash", "Snippet permanently deleted.")
+
+	http.Redirect(w, r, "/account/trash", http.StatusSeeOther)
+}
+
+// Number of unchanged lines shown around each change in a diff.
+const diffContext = 3
+
+// Compare two revisions of a snippet.
+func (self *application) snippetDiff(w http.ResponseWriter, r *http.Request) {
+	snippet, from, to, ok := self.revisionPair(w, r)
+	if !ok {
+		return
+	}
+
+	data := self.newTemplateData(r)
+	data.Snippet = snippet
+	data.FromRevision = from
+	data.ToRevision = to
+	data.Hunks = diff.Hunks(diff.Lines(from.Content, to.Content), diffContext)
+	data.SideBySide = r.URL.Query().Get("view") == "split"
+
+	self.render(w, http.StatusOK, "diff.html", data)
+}
+
+// Download the difference between two revisions as a unified diff.
+func (self *application) snippetPatch(w http.ResponseWriter, r *http.Request) {
+	snippet, from, to, ok := self.revisionPair(w, r)
+	if !ok {
+		return
+	}
+
+	name := "snippet-" + snippet.Slug
+	patch := diff.Unified("a/"+name, "b/"+name, from.Content, to.Content, diffContext)
+
+	w.Header().Set("Content-Type", "text/x-diff; charset=utf-8")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="%s-v%d-v%d.patch"`, name, from.Version, to.Version))
+
+	w.Write([]byte(patch))
+}
+
+// Look up a snippet and the two revisions named by the from and to query
+// parameters. Without them, the latest revision is compared with the one
+// before it. When ok is false a response has already been written.
+func (self *application) revisionPair(w http.ResponseWriter, r *http.Request) (snippet *models.Snippet, from, to *models.Revision, ok bool) {
+	snippet, ok = self.readHistoryParam(w, r)
+	if !ok {
+		return nil, nil, nil, false
+	}
+
+	revisions, err := self.snippets.Revisions(snippet.ID)
+	if err != nil {
+		self.serverError(w, err)
+		return nil, nil, nil, false
+	}
+
+	if len(revisions) == 0 {
+		self.notFound(w)
+		return nil, nil, nil, false
+	}
+
+	// Revisions are ordered newest first.
+	toVersion := revisions[0].Version
+	if v := r.URL.Query().Get("to"); v != "" {
+		toVersion, err = strconv.Atoi(v)
+		if err != nil {
+			self.clientError(w, http.StatusBadRequest)
+			return nil, nil, nil, false
+		}
+	}
+
+	fromVersion := max(toVersion-1, 1)
+	if v := r.URL.Query().Get("from"); v != "" {
+		fromVersion, err = strconv.Atoi(v)
+		if err != nil {
+			self.clientError(w, http.StatusBadRequest)
+			return nil, nil, nil, false
+		}
+	}
+
+	for _, rev := range revisions {
+		if rev.Version == fromVersion {
+			from = rev
+		}
+		if rev.Version == toVersion {
+			to = rev
+		}
+	}
+
+	if from == nil || to == nil {
+		self.notFound(w)
+		return nil, nil, nil, false
+	}
+
+	return snippet, from, to, true
+}
+
+// List the snippets carrying a tag.
+func (self *application) tagView(w http.ResponseWriter, r *http.Request) {
+	name := httprouter.ParamsFromContext(r.Context()).ByName("name")
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	page, err := self.snippets.ByTag(name, r.URL.Query().Get("cursor"), self.readPageSize(r))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCursor) {
+			self.clientError(w, http.StatusBadRequest)
+		} else {
+			self.serverError(w, err)
+		}
+		return
+	}
+
+	data := self.newTemplateData(r)
+	data.Tag = name
+	data.Snippets = page.Snippets
+	data.PrevPage, data.NextPage = pageLinks(r, page)
+
+	self.render(w, http.StatusOK, "tag.html", data)
+}
+
+// Tag names starting with the prefix query parameter, as a JSON array.
+func (self *application) tagSuggest(w http.ResponseWriter, r *http.Request) {
+	prefix := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("prefix")))
+
+	tags := []string{}
+	if prefix != "" {
+		var err error
+
+		tags, err = self.tags.Suggest(prefix, 10)
+		if err != nil {
+			self.serverError(w, err)
+			return
+		}
+	}
+
+	js, err := json.Marshal(tags)
+	if err != nil {
+		self.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(js)
+}
+
+type searchForm struct {
+	Query               string `form:"q"`
 	validator.Validator `form:"-"`
 }
 
-// Create snippet
-func (self *application) snippetCreatePost(w http.ResponseWriter, r *http.Request) {
-	var form snippetCreateForm
+func (self *application) search(w http.ResponseWriter, r *http.Request) {
+	form := searchForm{Query: r.URL.Query().Get("q")}
 
-	err := self.decodePostForm(r, &form)
-	if err != nil {
-		self.clientError(w, http.StatusBadRequest)
+	data := self.newTemplateData(r)
+
+	// Without a query, just show the search box.
+	if !validator.NotBlank(form.Query) {
+		data.Form = form
+		self.render(w, http.StatusOK, "search.html", data)
 		return
 	}
 
-	// Because the Validator type is embedded by the snippetCreateForm struct,
-	// we can call CheckField() directly on it.
-	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
-	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
-	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
-	form.CheckField(validator.PermittedValue(form.Expires, 1, 7, 365), "expires", "This field must equal 1, 7 or 365")
+	form.CheckField(validator.MaxChars(form.Query, 200), "q", "This field cannot be more than 200 characters long")
+
+	var q *query.Query
+	if form.Valid() {
+		var err error
+
+		q, err = query.Parse(form.Query)
+		if err != nil {
+			var syntaxError *query.SyntaxError
+			if !errors.As(err, &syntaxError) {
+				self.serverError(w, err)
+				return
+			}
+			form.AddFieldError("q", fmt.Sprintf("Invalid search: %s", syntaxError))
+		}
+	}
 
 	if !form.Valid() {
-		data := self.newTemplateData(r)
 		data.Form = form
-		self.render(w, http.StatusUnprocessableEntity, "create.html", data)
+		self.render(w, http.StatusUnprocessableEntity, "search.html", data)
 		return
 	}
 
-	id, err := self.snippets.Insert(form.Title, form.Content, form.Expires)
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	results, err := self.snippets.Search(q, page, self.readPageSize(r))
 	if err != nil {
-		self.serverError(w, err)
+		if errors.Is(err, models.ErrUnsupportedFilter) {
+			form.AddFieldError("q", "Invalid search: that filter isn't supported")
+			data.Form = form
+			self.render(w, http.StatusUnprocessableEntity, "search.html", data)
+		} else {
+			self.serverError(w, err)
+		}
 		return
 	}
 
-	self.sessionManager.Put(r.Context(), "flash", "Snippet successfully created!")
+	data.Form = form
+	data.SearchResults = results.Results
+	if results.Page > 1 {
+		data.PrevPage = withQuery(r, "page", strconv.Itoa(results.Page-1))
+	}
+	if results.More {
+		data.NextPage = withQuery(r, "page", strconv.Itoa(results.Page+1))
+	}
 
-	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
+	self.render(w, http.StatusOK, "search.html", data)
 }
 
 type userSignupForm struct {
@@ -319,6 +1502,371 @@ func (self *application) accountPasswordUpdatePost(w http.ResponseWriter, r *htt
 	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
 }
 
+type collectionForm struct {
+	Title               string            `form:"title"`
+	Description         string            `form:"description"`
+	Visibility          models.Visibility `form:"visibility"`
+	validator.Validator `form:"-"`
+}
+
+func checkCollection(form *collectionForm) {
+	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
+	form.CheckField(validator.MaxChars(form.Description, 1000), "description", "This field cannot be more than 1000 characters long")
+	form.CheckField(validator.PermittedValue(form.Visibility, visibilities...), "visibility", "This field must equal public, unlisted or private")
+}
+
+// Look up the collection named by the id URL parameter, a slug, writing a
+// not found response when there's none the viewer may see.
+func (self *application) readCollectionParam(w http.ResponseWriter, r *http.Request) (*models.Collection, bool) {
+	slug := httprouter.ParamsFromContext(r.Context()).ByName("id")
+	viewerID := self.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	collection, err := self.collections.Get(slug, viewerID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			self.notFound(w)
+		} else {
+			self.serverError(w, err)
+		}
+		return nil, false
+	}
+
+	return collection, true
+}
+
+// Like readCollectionParam, for collections the current user owns.
+func (self *application) readOwnCollection(w http.ResponseWriter, r *http.Request) (*models.Collection, bool) {
+	collection, ok := self.readCollectionParam(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if collection.UserID != self.sessionManager.GetInt(r.Context(), "authenticatedUserID") {
+		self.notFound(w)
+		return nil, false
+	}
+
+	return collection, true
+}
+
+func (self *application) collectionView(w http.ResponseWriter, r *http.Request) {
+	collection, ok := self.readCollectionParam(w, r)
+	if !ok {
+		return
+	}
+
+	data := self.newTemplateData(r)
+	data.Collection = collection
+
+	self.render(w, http.StatusOK, "collection.html", data)
+}
+
+// Serve the snippets of a collection as a zip file, with a directory for
+// each snippet holding its files. Snippets that are view-limited or still
+// locked by a password are left out, as downloading them here would get
+// around those.
+func (self *application) collectionDownload(w http.ResponseWriter, r *http.Request) {
+	collection, ok := self.readCollectionParam(w, r)
+	if !ok {
+		return
+	}
+
+	viewerID := self.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+
+	for i, s := range collection.Snippets {
+		if s.ViewsLeft != nil || self.isLocked(r, s) {
+			continue
+		}
+
+		// Only single snippets are looked up with their files.
+		snippet, err := self.snippets.Get(s.ID, viewerID)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				continue
+			}
+			self.serverError(w, err)
+			return
+		}
+
+		dir := fmt.Sprintf("%02d-%s/", i+1, snippet.Slug)
+
+		err = writeZipFile(zw, dir+downloadFilename(snippet), snippet.Content)
+		if err != nil {
+			self.serverError(w, err)
+			return
+		}
+
+		for _, f := range snippet.Files {
+			err = writeZipFile(zw, dir+f.Filename, f.Content)
+			if err != nil {
+				self.serverError(w, err)
+				return
+			}
+		}
+	}
+
+	err := zw.Close()
+	if err != nil {
+		self.serverError(w, err)
+		return
+	}
+
+	name := titleSlug(collection.Title)
+	if name == "" {
+		name = "collection-" + collection.Slug
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, name))
+	w.Write(buf.Bytes())
+}
+
+func writeZipFile(zw *zip.Writer, name string, content string) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(f, content)
+	return err
+}
+
+// List the current user's collections.
+func (self *application) accountCollections(w http.ResponseWriter, r *http.Request) {
+	userID := self.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	collections, err := self.collections.ByOwner(userID)
+	if err != nil {
+		self.serverError(w, err)
+		return
+	}
+
+	data := self.newTemplateData(r)
+	data.Collections = collections
+
+	self.render(w, http.StatusOK, "collections.html", data)
+}
+
+func (self *application) collectionCreate(w http.ResponseWriter, r *http.Request) {
+	data := self.newTemplateData(r)
+	data.Form = collectionForm{Visibility: models.VisibilityPublic}
+
+	self.render(w, http.StatusOK, "collection_form.html", data)
+}
+
+func (self *application) collectionCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form collectionForm
+
+	err := self.decodePostForm(r, &form)
+	if err != nil {
+		self.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	checkCollection(&form)
+
+	if !form.Valid() {
+		data := self.newTemplateData(r)
+		data.Form = form
+		self.render(w, http.StatusUnprocessableEntity, "collection_form.html", data)
+		return
+	}
+
+	userID := self.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	slug, err := self.collections.Insert(userID, form.Title, form.Description, form.Visibility)
+	if err != nil {
+		self.serverError(w, err)
+		return
+	}
+
+	self.sessionManager.Put(r.Context(), "flash", "Collection successfully created!")
+
+	http.Redirect(w, r, "/collection/"+slug, http.StatusSeeOther)
+}
+
+func (self *application) collectionEdit(w http.ResponseWriter, r *http.Request) {
+	collection, ok := self.readOwnCollection(w, r)
+	if !ok {
+		return
+	}
+
+	data := self.newTemplateData(r)
+	data.Collection = collection
+	data.Form = collectionForm{
+		Title:       collection.Title,
+		Description: collection.Description,
+		Visibility:  collection.Visibility,
+	}
+
+	self.render(w, http.StatusOK, "collection_form.html", data)
+}
+
+func (self *application) collectionEditPost(w http.ResponseWriter, r *http.Request) {
+	collection, ok := self.readOwnCollection(w, r)
+	if !ok {
+		return
+	}
+
+	var form collectionForm
+
+	err := self.decodePostForm(r, &form)
+	if err != nil {
+		self.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	checkCollection(&form)
+
+	if !form.Valid() {
+		data := self.newTemplateData(r)
+		data.Collection = collection
+		data.Form = form
+		self.render(w, http.StatusUnprocessableEntity, "collection_form.html", data)
+		return
+	}
+
+	err = self.collections.Update(collection.ID, collection.UserID, form.Title, form.Description, form.Visibility)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			self.notFound(w)
+		} else {
+			self.serverError(w, err)
+		}
+		return
+	}
+
+	self.sessionManager.Put(r.Context(), "flash", "Collection successfully updated!")
+
+	http.Redirect(w, r, "/collection/"+collection.Slug, http.StatusSeeOther)
+}
+
+func (self *application) collectionDeletePost(w http.ResponseWriter, r *http.Request) {
+	collection, ok := self.readOwnCollection(w, r)
+	if !ok {
+		return
+	}
+
+	err := self.collections.Delete(collection.ID, collection.UserID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			self.notFound(w)
+		} else {
+			self.serverError(w, err)
+		}
+		return
+	}
+
+	self.sessionManager.Put(r.Context(), "flash", "Collection deleted. The snippets in it were kept.")
+
+	http.Redirect(w, r, "/account/collections", http.StatusSeeOther)
+}
+
+type collectionSnippetForm struct {
+	CollectionID int `form:"collection_id"`
+	SnippetID    int `form:"snippet_id"`
+	Offset       int `form:"offset"`
+}
+
+// Add a snippet to one of the current user's collections.
+func (self *application) snippetCollectPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := self.readSnippetParam(w, r)
+	if !ok {
+		return
+	}
+
+	// Opening it from the collection would use up a view.
+	if snippet.ViewsLeft != nil {
+		self.notFound(w)
+		return
+	}
+
+	var form collectionSnippetForm
+
+	err := self.decodePostForm(r, &form)
+	if err != nil {
+		self.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	userID := self.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	err = self.collections.AddSnippet(form.CollectionID, userID, snippet.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			self.notFound(w)
+		} else {
+			self.serverError(w, err)
+		}
+		return
+	}
+
+	self.sessionManager.Put(r.Context(), "flash", "Snippet added to the collection.")
+
+	http.Redirect(w, r, "/snippet/view/"+snippet.Slug, http.StatusSeeOther)
+}
+
+// Take a snippet out of a collection owned by the current user.
+func (self *application) collectionRemovePost(w http.ResponseWriter, r *http.Request) {
+	collection, ok := self.readOwnCollection(w, r)
+	if !ok {
+		return
+	}
+
+	var form collectionSnippetForm
+
+	err := self.decodePostForm(r, &form)
+	if err != nil {
+		self.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = self.collections.RemoveSnippet(collection.ID, collection.UserID, form.SnippetID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			self.notFound(w)
+		} else {
+			self.serverError(w, err)
+		}
+		return
+	}
+
+	http.Redirect(w, r, "/collection/"+collection.Slug, http.StatusSeeOther)
+}
+
+// Move a snippet one place up or down a collection owned by the current
+// user.
+func (self *application) collectionMovePost(w http.ResponseWriter, r *http.Request) {
+	collection, ok := self.readOwnCollection(w, r)
+	if !ok {
+		return
+	}
+
+	var form collectionSnippetForm
+
+	err := self.decodePostForm(r, &form)
+	if err != nil || (form.Offset != -1 && form.Offset != 1) {
+		self.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = self.collections.MoveSnippet(collection.ID, collection.UserID, form.SnippetID, form.Offset)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			self.notFound(w)
+		} else {
+			self.serverError(w, err)
+		}
+		return
+	}
+
+	http.Redirect(w, r, "/collection/"+collection.Slug, http.StatusSeeOther)
+}
+
 func ping(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("OK"))
 }
@@ -1,11 +1,18 @@
 package main
 
 import (
+	"archive/zip"
 	"net/http"
 	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"snippetbox.davc.io/internal/assert"
+	"snippetbox.davc.io/internal/models"
+	"snippetbox.davc.io/internal/models/mocks"
 )
 
 func TestPing(t *testing.T) {
@@ -21,6 +28,66 @@ func TestPing(t *testing.T) {
 	assert.Equal(t, body, "OK")
 }
 
+func TestHome(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "First page",
+			urlPath:  "/",
+			wantCode: http.StatusOK,
+			wantBody: "An old silent pond",
+		},
+		{
+			name:     "Tag cloud",
+			urlPath:  "/",
+			wantCode: http.StatusOK,
+			wantBody: "<a href='/tag/haiku' class='tag-weight-1'",
+		},
+		{
+			name:     "Later page",
+			urlPath:  "/?cursor=" + models.Cursor{Created: time.Now(), ID: 1}.String(),
+			wantCode: http.StatusOK,
+			wantBody: "There's nothing to see here... yet!",
+		},
+		{
+			name:     "Invalid cursor",
+			urlPath:  "/?cursor=foo",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestPageLinks(t *testing.T) {
+	r, err := http.NewRequest(http.MethodGet, "/account/view?size=20&cursor=old", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prev, next := pageLinks(r, &models.SnippetPage{Next: "abc"})
+
+	assert.Equal(t, prev, "")
+	assert.Equal(t, next, "/account/view?cursor=abc&size=20")
+}
+
 func TestSnippetCreate(t *testing.T) {
 	app := newTestApplication(t)
 
@@ -47,10 +114,140 @@ func TestSnippetCreate(t *testing.T) {
 		code, _, body := ts.get(t, "/snippet/create")
 
 		assert.Equal(t, code, http.StatusOK)
-		assert.StringContains(t, body, "<form action='/snippet/create' method='POST'>")
+		assert.StringContains(t, body, "<form action='/snippet/create' method='POST' enctype='multipart/form-data'>")
 	})
 }
 
+func TestSnippetCreateExpiry(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippet/create")
+	csrfToken := extractCSRFToken(t, body)
+
+	tomorrow := time.Now().UTC().Add(24 * time.Hour).Format(expiresAtLayout)
+
+	tests := []struct {
+		name      string
+		maxExpiry time.Duration
+		expiry    string
+		in        string
+		unit      string
+		at        string
+		wantCode  int
+		wantBody  string
+	}{
+		{
+			name:     "Minutes",
+			expiry:   "after",
+			in:       "30",
+			unit:     "minutes",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Weeks",
+			expiry:   "after",
+			in:       "3",
+			unit:     "weeks",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Fixed time",
+			expiry:   "on",
+			at:       tomorrow,
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Never",
+			expiry:   "never",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Below minimum",
+			expiry:   "after",
+			in:       "1",
+			unit:     "minutes",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Snippets cannot expire sooner than 5 minutes from now",
+		},
+		{
+			name:      "Above maximum",
+			maxExpiry: 7 * 24 * time.Hour,
+			expiry:    "after",
+			in:        "8",
+			unit:      "days",
+			wantCode:  http.StatusUnprocessableEntity,
+			wantBody:  "Snippets cannot expire later than 1 week from now",
+		},
+		{
+			name:      "Never with maximum",
+			maxExpiry: 7 * 24 * time.Hour,
+			expiry:    "never",
+			wantCode:  http.StatusUnprocessableEntity,
+			wantBody:  "Snippets must expire within 1 week",
+		},
+		{
+			name:     "Past time",
+			expiry:   "on",
+			at:       "2020-01-01T00:00",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Snippets cannot expire sooner than 5 minutes from now",
+		},
+		{
+			name:     "Invalid time",
+			expiry:   "on",
+			at:       "tomorrow",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field must be a date and time",
+		},
+		{
+			name:     "Invalid unit",
+			expiry:   "after",
+			in:       "3",
+			unit:     "months",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field must be in minutes, hours, days or weeks",
+		},
+		{
+			name:     "Too long",
+			expiry:   "after",
+			in:       "999999999",
+			unit:     "weeks",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field must be a whole number within 100 years",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app.maxExpiry = tt.maxExpiry
+
+			form := url.Values{}
+			form.Add("title", "An old silent pond")
+			form.Add("content", "An old silent pond...")
+			form.Add("language", "plaintext")
+			form.Add("visibility", "public")
+			form.Add("max_views", "0")
+			form.Add("expiry", tt.expiry)
+			form.Add("expires_in", tt.in)
+			form.Add("expires_unit", tt.unit)
+			form.Add("expires_at", tt.at)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/snippet/create", form)
+
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
 func TestSnippetView(t *testing.T) {
 	app := newTestApplication(t)
 
@@ -58,17 +255,56 @@ func TestSnippetView(t *testing.T) {
 	defer ts.Close()
 
 	tests := []struct {
-		name     string
-		urlPath  string
-		wantCode int
-		wantBody string
+		name         string
+		urlPath      string
+		wantCode     int
+		wantBody     string
+		wantLocation string
 	}{
 		{
-			name:     "Valid ID",
-			urlPath:  "/snippet/view/1",
+			name:     "Valid slug",
+			urlPath:  "/snippet/view/Bq3xT7nLwP",
 			wantCode: http.StatusOK,
 			wantBody: "An old silent pond...",
 		},
+		{
+			name:     "Highlighted",
+			urlPath:  "/snippet/view/Bq3xT7nLwP",
+			wantCode: http.StatusOK,
+			wantBody: `<pre class="chroma"><code><span class="line">`,
+		},
+		{
+			name:     "Unlisted",
+			urlPath:  "/snippet/view/Hn6tY2cVbX",
+			wantCode: http.StatusOK,
+			wantBody: "For whoever has the link",
+		},
+		{
+			name:     "Non-existent slug",
+			urlPath:  "/snippet/view/Aa1Bb2Cc3D",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Private",
+			urlPath:  "/snippet/view/Zr4kP8dQmT",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:         "Numeric ID",
+			urlPath:      "/snippet/view/1",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "/snippet/view/Bq3xT7nLwP",
+		},
+		{
+			name:     "Numeric ID, unlisted",
+			urlPath:  "/snippet/view/5",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Numeric ID, private",
+			urlPath:  "/snippet/view/4",
+			wantCode: http.StatusNotFound,
+		},
 		{
 			name:     "Non-existent ID",
 			urlPath:  "/snippet/view/2",
@@ -95,6 +331,1698 @@ func TestSnippetView(t *testing.T) {
 		},
 	}
 
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, body := ts.get(t, tt.urlPath)
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+			if tt.wantLocation != "" {
+				assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+			}
+		})
+	}
+
+	ts.login(t)
+
+	t.Run("Private, owner", func(t *testing.T) {
+		code, _, body := ts.get(t, "/snippet/view/Zr4kP8dQmT")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "For my eyes only")
+	})
+
+	t.Run("Numeric ID, owner", func(t *testing.T) {
+		code, headers, _ := ts.get(t, "/snippet/view/5")
+
+		assert.Equal(t, code, http.StatusMovedPermanently)
+		assert.Equal(t, headers.Get("Location"), "/snippet/view/Hn6tY2cVbX")
+	})
+}
+
+func TestSnippetFork(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Source", func(t *testing.T) {
+		code, _, body := ts.get(t, "/snippet/view/Bq3xT7nLwP")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "1 fork &middot; 2 stars</span>")
+		assert.StringContains(t, body, "<td><a href='/snippet/view/Fk9pW3sLmV'>An old silent pond, again</a></td>")
+	})
+
+	t.Run("Fork", func(t *testing.T) {
+		code, _, body := ts.get(t, "/snippet/view/Fk9pW3sLmV")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "forked from <a href='/snippet/view/Bq3xT7nLwP'>#1</a>")
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		_, _, body := ts.get(t, "/user/login")
+		csrfToken := extractCSRFToken(t, body)
+
+		form := url.Values{}
+		form.Add("csrf_token", csrfToken)
+		code, headers, _ := ts.postForm(t, "/snippet/fork/Bq3xT7nLwP", form)
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/login")
+	})
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippet/view/Bq3xT7nLwP")
+	assert.StringContains(t, body, "<form action='/snippet/fork/Bq3xT7nLwP' method='POST'>")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Public",
+			urlPath:      "/snippet/fork/Bq3xT7nLwP",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/Fk3mQ7vBnZ",
+		},
+		{
+			name:     "View limited",
+			urlPath:  "/snippet/fork/Bn8rA4tLeX",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Password protected",
+			urlPath:  "/snippet/fork/Pw7dK3mNqZ",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Non-existent slug",
+			urlPath:  "/snippet/fork/Aaaaaaaaaa",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+			code, headers, _ := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+		})
+	}
+}
+
+func TestSnippetStar(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		_, _, body := ts.get(t, "/user/login")
+		csrfToken := extractCSRFToken(t, body)
+
+		form := url.Values{}
+		form.Add("csrf_token", csrfToken)
+		code, headers, _ := ts.postForm(t, "/snippet/star/Bq3xT7nLwP", form)
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/login")
+	})
+
+	ts.login(t)
+
+	t.Run("Buttons", func(t *testing.T) {
+		_, _, body := ts.get(t, "/snippet/view/Bq3xT7nLwP")
+		assert.StringContains(t, body, "<form action='/snippet/star/Bq3xT7nLwP' method='POST'>")
+
+		_, _, body = ts.get(t, "/snippet/view/Fk9pW3sLmV")
+		assert.StringContains(t, body, "<form action='/snippet/unstar/Fk9pW3sLmV' method='POST'>")
+	})
+
+	t.Run("Missing CSRF token", func(t *testing.T) {
+		code, _, _ := ts.postForm(t, "/snippet/star/Bq3xT7nLwP", url.Values{})
+
+		assert.Equal(t, code, http.StatusBadRequest)
+	})
+
+	_, _, body := ts.get(t, "/snippet/view/Bq3xT7nLwP")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Star",
+			urlPath:      "/snippet/star/Bq3xT7nLwP",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/Bq3xT7nLwP",
+		},
+		{
+			name:         "Unstar",
+			urlPath:      "/snippet/unstar/Fk9pW3sLmV",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/Fk9pW3sLmV",
+		},
+		{
+			name:     "View limited",
+			urlPath:  "/snippet/star/Bn8rA4tLeX",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Non-existent slug",
+			urlPath:  "/snippet/star/Aaaaaaaaaa",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+			code, headers, _ := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+		})
+	}
+
+	t.Run("Starred snippets", func(t *testing.T) {
+		code, _, body := ts.get(t, "/account/stars")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "<td><a href='/snippet/view/Fk9pW3sLmV'>An old silent pond, again</a></td>")
+	})
+
+	t.Run("Invalid cursor", func(t *testing.T) {
+		code, _, _ := ts.get(t, "/account/stars?cursor=%21")
+
+		assert.Equal(t, code, http.StatusBadRequest)
+	})
+}
+
+func TestCollection(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("View", func(t *testing.T) {
+		code, _, body := ts.get(t, "/collection/Cl5vR8nWqT")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "<h2>Poems and code</h2>")
+		assert.StringContains(t, body, "<a href='/snippet/view/Mf4tG8hJkL'>")
+		assert.Equal(t, strings.Contains(body, "/collection/Cl5vR8nWqT/remove"), false)
+	})
+
+	t.Run("Private", func(t *testing.T) {
+		code, _, _ := ts.get(t, "/collection/Cp3xH7kMzD")
+
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+
+	t.Run("Download", func(t *testing.T) {
+		code, headers, body := ts.get(t, "/collection/Cl5vR8nWqT/download")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, headers.Get("Content-Type"), "application/zip")
+		assert.Equal(t, headers.Get("Content-Disposition"), `attachment; filename="poems-and-code.zip"`)
+
+		zr, err := zip.NewReader(strings.NewReader(body), int64(len(body)))
+		assert.NilError(t, err)
+
+		var names []string
+		for _, f := range zr.File {
+			names = append(names, f.Name)
+		}
+
+		// The password protected snippet is left out.
+		assert.Equal(t, strings.Join(names, " "), "01-Bq3xT7nLwP/an-old-silent-pond.txt 02-Mf4tG8hJkL/main.go 02-Mf4tG8hJkL/go.mod")
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		code, headers, _ := ts.get(t, "/collection/Cl5vR8nWqT/edit")
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/login")
+	})
+
+	ts.login(t)
+
+	t.Run("Owner", func(t *testing.T) {
+		code, _, body := ts.get(t, "/collection/Cl5vR8nWqT")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "<form action='/collection/Cl5vR8nWqT/remove' method='POST'>")
+		assert.StringContains(t, body, "<a href='/snippet/view/Zr4kP8dQmT'>")
+	})
+
+	t.Run("Account", func(t *testing.T) {
+		code, _, body := ts.get(t, "/account/collections")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "<td><a href='/collection/Cp3xH7kMzD'>Drafts</a></td>")
+	})
+
+	t.Run("Add button", func(t *testing.T) {
+		_, _, body := ts.get(t, "/snippet/view/Bq3xT7nLwP")
+
+		assert.StringContains(t, body, "<form action='/snippet/collect/Bq3xT7nLwP' method='POST'>")
+		assert.StringContains(t, body, "<option value='1'>Poems and code</option>")
+	})
+
+	_, _, body := ts.get(t, "/account/collections/create")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		form         url.Values
+		wantCode     int
+		wantLocation string
+		wantBody     string
+	}{
+		{
+			name:         "Create",
+			urlPath:      "/account/collections/create",
+			form:         url.Values{"title": {"Haiku"}, "visibility": {"public"}},
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/collection/Cn2wB6rTyU",
+		},
+		{
+			name:     "Create blank title",
+			urlPath:  "/account/collections/create",
+			form:     url.Values{"title": {""}, "visibility": {"public"}},
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field cannot be blank",
+		},
+		{
+			name:     "Edit invalid visibility",
+			urlPath:  "/collection/Cl5vR8nWqT/edit",
+			form:     url.Values{"title": {"Poems"}, "visibility": {"secret"}},
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field must equal public, unlisted or private",
+		},
+		{
+			name:         "Edit",
+			urlPath:      "/collection/Cl5vR8nWqT/edit",
+			form:         url.Values{"title": {"Poems"}, "visibility": {"unlisted"}},
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/collection/Cl5vR8nWqT",
+		},
+		{
+			name:         "Add",
+			urlPath:      "/snippet/collect/Bq3xT7nLwP",
+			form:         url.Values{"collection_id": {"1"}},
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/Bq3xT7nLwP",
+		},
+		{
+			name:     "Add to someone else's",
+			urlPath:  "/snippet/collect/Bq3xT7nLwP",
+			form:     url.Values{"collection_id": {"99"}},
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Add view limited",
+			urlPath:  "/snippet/collect/Bn8rA4tLeX",
+			form:     url.Values{"collection_id": {"1"}},
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:         "Move",
+			urlPath:      "/collection/Cl5vR8nWqT/move",
+			form:         url.Values{"snippet_id": {"1"}, "offset": {"1"}},
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/collection/Cl5vR8nWqT",
+		},
+		{
+			name:     "Move too far",
+			urlPath:  "/collection/Cl5vR8nWqT/move",
+			form:     url.Values{"snippet_id": {"1"}, "offset": {"2"}},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Move missing snippet",
+			urlPath:  "/collection/Cl5vR8nWqT/move",
+			form:     url.Values{"snippet_id": {"7"}, "offset": {"-1"}},
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:         "Remove",
+			urlPath:      "/collection/Cl5vR8nWqT/remove",
+			form:         url.Values{"snippet_id": {"1"}},
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/collection/Cl5vR8nWqT",
+		},
+		{
+			name:     "Non-existent slug",
+			urlPath:  "/collection/Aaaaaaaaaa/delete",
+			form:     url.Values{},
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:         "Delete",
+			urlPath:      "/collection/Cl5vR8nWqT/delete",
+			form:         url.Values{},
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/account/collections",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.form.Add("csrf_token", csrfToken)
+			code, headers, body := ts.postForm(t, tt.urlPath, tt.form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetEmbed(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Public",
+			urlPath:  "/embed/Bq3xT7nLwP",
+			wantCode: http.StatusOK,
+			wantBody: "<a href='/snippet/view/Bq3xT7nLwP' target='_blank' rel='noopener'>An old silent pond</a>",
+		},
+		{
+			name:     "Unlisted",
+			urlPath:  "/embed/Hn6tY2cVbX",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Multiple files",
+			urlPath:  "/embed/Mf4tG8hJkL",
+			wantCode: http.StatusOK,
+			wantBody: "<div class='file-header'>go.mod &middot; Plain text</div>",
+		},
+		{
+			name:     "Private",
+			urlPath:  "/embed/Zr4kP8dQmT",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Password protected",
+			urlPath:  "/embed/Pw7dK3mNqZ",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "View limited",
+			urlPath:  "/embed/Bn8rA4tLeX",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "ID",
+			urlPath:  "/embed/1",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+
+			if code == http.StatusOK {
+				assert.StringContains(t, headers.Get("Content-Security-Policy"), "; frame-ancestors https://wiki.example.com")
+				assert.Equal(t, headers.Get("X-Frame-Options"), "")
+			}
+		})
+	}
+
+	t.Run("Elsewhere", func(t *testing.T) {
+		_, headers, _ := ts.get(t, "/snippet/view/Bq3xT7nLwP")
+
+		assert.Equal(t, headers.Get("X-Frame-Options"), "deny")
+	})
+}
+
+func TestSnippetRaw(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Public",
+			urlPath:  "/snippet/raw/Bq3xT7nLwP",
+			wantCode: http.StatusOK,
+			wantBody: "An old silent pond...",
+		},
+		{
+			name:     "Unlisted",
+			urlPath:  "/snippet/raw/Hn6tY2cVbX",
+			wantCode: http.StatusOK,
+			wantBody: "For whoever has the link",
+		},
+		{
+			name:     "View limited",
+			urlPath:  "/snippet/raw/Bn8rA4tLeX",
+			wantCode: http.StatusOK,
+			wantBody: "token: 5f3c",
+		},
+		{
+			name:     "No views left",
+			urlPath:  "/snippet/raw/Sp2nT6wQeR",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Private",
+			urlPath:  "/snippet/raw/Zr4kP8dQmT",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Password protected",
+			urlPath:  "/snippet/raw/Pw7dK3mNqZ",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Non-existent slug",
+			urlPath:  "/snippet/raw/Aaaaaaaaaa",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantCode == http.StatusOK {
+				assert.Equal(t, body, tt.wantBody)
+				assert.Equal(t, headers.Get("Content-Type"), "text/plain; charset=utf-8")
+				assert.Equal(t, headers.Get("X-Content-Type-Options"), "nosniff")
+				assert.Equal(t, headers.Get("Content-Security-Policy"), "default-src 'none'; sandbox")
+			}
+		})
+	}
+
+	t.Run("Download", func(t *testing.T) {
+		code, headers, body := ts.get(t, "/snippet/download/Bq3xT7nLwP")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, body, "An old silent pond...")
+		assert.Equal(t, headers.Get("Content-Type"), "text/plain; charset=utf-8")
+		assert.Equal(t, headers.Get("Content-Disposition"), `attachment; filename="an-old-silent-pond.txt"`)
+	})
+
+	t.Run("Download private", func(t *testing.T) {
+		code, _, _ := ts.get(t, "/snippet/download/Zr4kP8dQmT")
+
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+}
+
+func TestSnippetStats(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Views recorded", func(t *testing.T) {
+		ts.get(t, "/snippet/view/Bq3xT7nLwP")
+		ts.get(t, "/snippet/raw/Bq3xT7nLwP")
+
+		assert.Equal(t, len(app.viewRecorder.views), 2)
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		code, headers, _ := ts.get(t, "/snippet/stats/Bq3xT7nLwP")
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/login")
+	})
+
+	ts.login(t)
+
+	t.Run("Own views not recorded", func(t *testing.T) {
+		ts.get(t, "/snippet/view/Bq3xT7nLwP")
+
+		assert.Equal(t, len(app.viewRecorder.views), 2)
+	})
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Owner",
+			urlPath:  "/snippet/stats/Bq3xT7nLwP",
+			wantCode: http.StatusOK,
+			wantBody: "<td>news.ycombinator.com</td>",
+		},
+		{
+			name:     "Chart",
+			urlPath:  "/snippet/stats/Bq3xT7nLwP",
+			wantCode: http.StatusOK,
+			wantBody: "aria-label='3 views over the last 30 days'",
+		},
+		{
+			name:     "Someone else's",
+			urlPath:  "/snippet/stats/Fk9pW3sLmV",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Non-existent slug",
+			urlPath:  "/snippet/stats/Aaaaaaaaaa",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetFiles(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("View", func(t *testing.T) {
+		code, _, body := ts.get(t, "/snippet/view/Mf4tG8hJkL")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "<div class='file-header' id='file-1'>")
+		assert.StringContains(t, body, "<a href='#file-1'>main.go</a>")
+		assert.StringContains(t, body, "<div class='file-header' id='file-2'>")
+		assert.StringContains(t, body, "<a class='raw' href='/snippet/raw/Mf4tG8hJkL/go.mod'>Raw</a>")
+		assert.StringContains(t, body, `id="file-2-L1"`)
+	})
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantBody     string
+		wantLocation string
+	}{
+		{
+			name:     "First file",
+			urlPath:  "/snippet/raw/Mf4tG8hJkL/main.go",
+			wantCode: http.StatusOK,
+			wantBody: "package main",
+		},
+		{
+			name:     "Second file",
+			urlPath:  "/snippet/raw/Mf4tG8hJkL/go.mod",
+			wantCode: http.StatusOK,
+			wantBody: "module hello",
+		},
+		{
+			name:     "Missing file",
+			urlPath:  "/snippet/raw/Mf4tG8hJkL/go.sum",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Unnamed first file",
+			urlPath:  "/snippet/raw/Bq3xT7nLwP/snippet.txt",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Private",
+			urlPath:  "/snippet/raw/Zr4kP8dQmT/note.txt",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:         "Numeric ID",
+			urlPath:      "/snippet/raw/10/go.mod",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "/snippet/raw/Mf4tG8hJkL/go.mod",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+
+			if tt.wantCode == http.StatusOK {
+				assert.Equal(t, body, tt.wantBody)
+				assert.Equal(t, headers.Get("Content-Type"), "text/plain; charset=utf-8")
+			}
+		})
+	}
+
+	t.Run("Download", func(t *testing.T) {
+		_, headers, _ := ts.get(t, "/snippet/download/Mf4tG8hJkL")
+
+		assert.Equal(t, headers.Get("Content-Disposition"), `attachment; filename="main.go"`)
+	})
+}
+
+func TestSnippetCreateFiles(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippet/create")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name     string
+		filename string
+		files    map[string]string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Valid",
+			filename: "main.go",
+			files: map[string]string{
+				"files[0].filename": "go.mod",
+				"files[0].content":  "module hello",
+				"files[0].language": "auto",
+			},
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name: "Missing filename",
+			files: map[string]string{
+				"files[0].content":  "module hello",
+				"files[0].language": "auto",
+			},
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Every file after the first needs a filename",
+		},
+		{
+			name:     "Duplicate filename",
+			filename: "main.go",
+			files: map[string]string{
+				"files[0].filename": "main.go",
+				"files[0].content":  "package main",
+				"files[0].language": "go",
+			},
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Each file needs a different filename",
+		},
+		{
+			name:     "Invalid filename",
+			filename: "../main.go",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Filenames can only contain letters, digits",
+		},
+		{
+			name:     "Blank file",
+			filename: "main.go",
+			files: map[string]string{
+				"files[0].filename": "go.mod",
+				"files[0].language": "auto",
+			},
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Files cannot be blank",
+		},
+		{
+			name:     "Long file",
+			filename: "main.go",
+			files: map[string]string{
+				"files[0].filename": "go.mod",
+				"files[0].content":  strings.Repeat("a", maxContentLength+1),
+				"files[0].language": "auto",
+			},
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Files cannot be more than 512K characters long",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "Hello module")
+			form.Add("filename", tt.filename)
+			form.Add("content", "package main")
+			form.Add("language", "go")
+			form.Add("visibility", "public")
+			form.Add("max_views", "0")
+			form.Add("expiry", "never")
+			form.Add("csrf_token", csrfToken)
+			for key, value := range tt.files {
+				form.Add(key, value)
+			}
+
+			code, _, body := ts.postForm(t, "/snippet/create", form)
+
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetCreateUpload(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippet/create")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name       string
+		visibility string
+		files      map[string]string
+		wantCode   int
+		wantBody   string
+	}{
+		{
+			name:       "Valid",
+			visibility: "public",
+			files:      map[string]string{"hello.py": "print('hello')"},
+			wantCode:   http.StatusSeeOther,
+		},
+		{
+			name:       "Defaults from filename",
+			visibility: "secret",
+			files:      map[string]string{"hello.py": "print('hello')"},
+			wantCode:   http.StatusUnprocessableEntity,
+			wantBody:   "<input type='text' name='title' value='hello.py'>",
+		},
+		{
+			name:       "Too large",
+			visibility: "public",
+			files:      map[string]string{"big.txt": strings.Repeat("a", maxUploadSize+1)},
+			wantCode:   http.StatusUnprocessableEntity,
+			wantBody:   "big.txt is larger than 512 KB",
+		},
+		{
+			name:       "Request too large",
+			visibility: "public",
+			files:      map[string]string{"huge.txt": strings.Repeat("a", maxUploadRequest+1)},
+			wantCode:   http.StatusRequestEntityTooLarge,
+			wantBody:   "The form is too large to send. Uploads can be at most 512 KB each, and 10 files altogether.",
+		},
+		{
+			name:       "Binary",
+			visibility: "public",
+			files:      map[string]string{"logo.png": "\x89PNG\r\n\x1a\n\x00\x00"},
+			wantCode:   http.StatusUnprocessableEntity,
+			wantBody:   "logo.png looks like a binary file",
+		},
+		{
+			name:       "Not UTF-8",
+			visibility: "public",
+			files:      map[string]string{"latin1.txt": "caf\xe9"},
+			wantCode:   http.StatusUnprocessableEntity,
+			wantBody:   "latin1.txt isn&#39;t UTF-8 text",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("language", "auto")
+			form.Add("visibility", tt.visibility)
+			form.Add("max_views", "0")
+			form.Add("expiry", "never")
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postMultipart(t, "/snippet/create", form, tt.files)
+
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestDownloadFilename(t *testing.T) {
+	tests := []struct {
+		name    string
+		snippet *models.Snippet
+		want    string
+	}{
+		{
+			name:    "Title",
+			snippet: &models.Snippet{Title: "Parse a CSV file!", Language: "go"},
+			want:    "parse-a-csv-file.go",
+		},
+		{
+			name:    "Punctuation only",
+			snippet: &models.Snippet{Slug: "Bq3xT7nLwP", Title: "???", Language: "python"},
+			want:    "snippet-Bq3xT7nLwP.py",
+		},
+		{
+			name:    "Unlisted language",
+			snippet: &models.Snippet{Title: "nginx.conf", Language: "nginx"},
+			want:    "nginx-conf.txt",
+		},
+		{
+			name:    "Long title",
+			snippet: &models.Snippet{Title: strings.Repeat("a", 100), Language: "sql"},
+			want:    strings.Repeat("a", 50) + ".sql",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, downloadFilename(tt.snippet), tt.want)
+		})
+	}
+}
+
+func TestSnippetUnlock(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/snippet/view/Pw7dK3mNqZ")
+
+	assert.Equal(t, code, http.StatusForbidden)
+	assert.StringContains(t, body, "<form action='/snippet/unlock/Pw7dK3mNqZ' method='POST' novalidate>")
+	csrfToken := extractCSRFToken(t, body)
+
+	t.Run("Wrong password", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("password", "abracadabra")
+		form.Add("csrf_token", csrfToken)
+		code, _, body := ts.postForm(t, "/snippet/unlock/Pw7dK3mNqZ", form)
+
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "Incorrect password")
+	})
+
+	t.Run("Right password", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("password", "open sesame")
+		form.Add("csrf_token", csrfToken)
+		code, headers, _ := ts.postForm(t, "/snippet/unlock/Pw7dK3mNqZ", form)
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/snippet/view/Pw7dK3mNqZ")
+
+		code, _, body := ts.get(t, "/snippet/view/Pw7dK3mNqZ")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "user: admin")
+	})
+}
+
+func TestSnippetUnlockRateLimit(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/snippet/view/Pw7dK3mNqZ")
+	csrfToken := extractCSRFToken(t, body)
+
+	// The test application allows 3 failures.
+	for i := 0; i < 3; i++ {
+		form := url.Values{}
+		form.Add("password", "abracadabra")
+		form.Add("csrf_token", csrfToken)
+		code, _, _ := ts.postForm(t, "/snippet/unlock/Pw7dK3mNqZ", form)
+
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+	}
+
+	form := url.Values{}
+	form.Add("password", "open sesame")
+	form.Add("csrf_token", csrfToken)
+	code, _, body := ts.postForm(t, "/snippet/unlock/Pw7dK3mNqZ", form)
+
+	assert.Equal(t, code, http.StatusTooManyRequests)
+	assert.StringContains(t, body, "Too many incorrect passwords")
+
+	t.Run("Owner", func(t *testing.T) {
+		ts.login(t)
+
+		code, _, _ := ts.get(t, "/snippet/view/Pw7dK3mNqZ")
+
+		assert.Equal(t, code, http.StatusOK)
+	})
+}
+
+// Counts the passwords checked, taking a while over each as bcrypt does.
+type slowPasswordModel struct {
+	mocks.SnippetModel
+	checks atomic.Int32
+}
+
+func (m *slowPasswordModel) CheckPassword(id int, password string) error {
+	m.checks.Add(1)
+	time.Sleep(50 * time.Millisecond)
+	return m.SnippetModel.CheckPassword(id, password)
+}
+
+func TestSnippetUnlockConcurrent(t *testing.T) {
+	app := newTestApplication(t)
+
+	model := &slowPasswordModel{}
+	app.snippets = model
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/snippet/view/Pw7dK3mNqZ")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("password", "abracadabra")
+	form.Add("csrf_token", csrfToken)
+
+	// Guesses sent at once all arrive before the first has failed.
+	codes := make(chan int, 20)
+
+	var wg sync.WaitGroup
+	for i := 0; i < cap(codes); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			rs, err := ts.Client().PostForm(ts.URL+"/snippet/unlock/Pw7dK3mNqZ", form)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			rs.Body.Close()
+			codes <- rs.StatusCode
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	refused := 0
+	for code := range codes {
+		if code == http.StatusTooManyRequests {
+			refused++
+		}
+	}
+
+	// The test application allows 3 failures.
+	assert.Equal(t, int(model.checks.Load()), 3)
+	assert.Equal(t, refused, cap(codes)-3)
+}
+
+func TestSnippetViewLimit(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Last view", func(t *testing.T) {
+		code, _, body := ts.get(t, "/snippet/view/Bn8rA4tLeX")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "token: 5f3c")
+		assert.StringContains(t, body, "This was the last view of this snippet.")
+	})
+
+	t.Run("No views left", func(t *testing.T) {
+		code, _, _ := ts.get(t, "/snippet/view/Sp2nT6wQeR")
+
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+
+	t.Run("History", func(t *testing.T) {
+		code, _, _ := ts.get(t, "/snippet/history/Bn8rA4tLeX")
+
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+
+	t.Run("Share unauthenticated", func(t *testing.T) {
+		code, headers, _ := ts.get(t, "/snippet/share/Bn8rA4tLeX")
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/login")
+	})
+
+	ts.login(t)
+
+	t.Run("Share", func(t *testing.T) {
+		code, _, body := ts.get(t, "/snippet/share/Bn8rA4tLeX")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "/snippet/view/Bn8rA4tLeX")
+		assert.StringContains(t, body, "Opening the link yourself counts as a view.")
+	})
+
+	t.Run("Share not owner", func(t *testing.T) {
+		code, _, _ := ts.get(t, "/snippet/share/Fk9pW3sLmV")
+
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+
+	t.Run("Create", func(t *testing.T) {
+		_, _, body := ts.get(t, "/snippet/create")
+		csrfToken := extractCSRFToken(t, body)
+
+		form := url.Values{}
+		form.Add("title", "One-time token")
+		form.Add("content", "token: 5f3c")
+		form.Add("language", "plaintext")
+		form.Add("visibility", "unlisted")
+		form.Add("expiry", "after")
+		form.Add("expires_in", "1")
+		form.Add("expires_unit", "days")
+		form.Add("csrf_token", csrfToken)
+
+		form.Set("max_views", "3")
+		code, _, body := ts.postForm(t, "/snippet/create", form)
+
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "This field must be one of the listed view limits")
+
+		form.Set("max_views", "1")
+		code, headers, _ := ts.postForm(t, "/snippet/create", form)
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/snippet/share/Xm2pL9sRtA")
+	})
+}
+
+func TestSnippetEdit(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		code, headers, _ := ts.get(t, "/snippet/edit/1")
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/login")
+	})
+
+	ts.login(t)
+
+	t.Run("Owner", func(t *testing.T) {
+		code, _, body := ts.get(t, "/snippet/edit/1")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "<form action='/snippet/edit/1' method='POST'>")
+		assert.StringContains(t, body, "An old silent pond...")
+	})
+
+	t.Run("Valid submission", func(t *testing.T) {
+		_, _, body := ts.get(t, "/snippet/edit/1")
+
+		form := url.Values{}
+		form.Add("title", "An old silent pond")
+		form.Add("content", "A frog jumps into the pond")
+		form.Add("language", "plaintext")
+		form.Add("visibility", "public")
+		form.Add("csrf_token", extractCSRFToken(t, body))
+		code, headers, _ := ts.postForm(t, "/snippet/edit/1", form)
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/snippet/view/Bq3xT7nLwP")
+	})
+
+	t.Run("Empty content", func(t *testing.T) {
+		_, _, body := ts.get(t, "/snippet/edit/1")
+
+		form := url.Values{}
+		form.Add("title", "An old silent pond")
+		form.Add("content", "")
+		form.Add("csrf_token", extractCSRFToken(t, body))
+		code, _, _ := ts.postForm(t, "/snippet/edit/1", form)
+
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+	})
+
+	t.Run("Unknown visibility", func(t *testing.T) {
+		_, _, body := ts.get(t, "/snippet/edit/1")
+
+		form := url.Values{}
+		form.Add("title", "An old silent pond")
+		form.Add("content", "A frog jumps into the pond")
+		form.Add("language", "plaintext")
+		form.Add("visibility", "secret")
+		form.Add("csrf_token", extractCSRFToken(t, body))
+		code, _, body := ts.postForm(t, "/snippet/edit/1", form)
+
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "This field must equal public, unlisted or private")
+	})
+
+	t.Run("Unknown language", func(t *testing.T) {
+		_, _, body := ts.get(t, "/snippet/edit/1")
+
+		form := url.Values{}
+		form.Add("title", "An old silent pond")
+		form.Add("content", "A frog jumps into the pond")
+		form.Add("language", "klingon")
+		form.Add("csrf_token", extractCSRFToken(t, body))
+		code, _, body := ts.postForm(t, "/snippet/edit/1", form)
+
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "This field must be one of the listed languages")
+	})
+
+	t.Run("Invalid tags", func(t *testing.T) {
+		_, _, body := ts.get(t, "/snippet/edit/1")
+
+		form := url.Values{}
+		form.Add("title", "An old silent pond")
+		form.Add("content", "A frog jumps into the pond")
+		form.Add("language", "plaintext")
+		form.Add("visibility", "public")
+		form.Add("tags", "haiku, no spaces")
+		form.Add("csrf_token", extractCSRFToken(t, body))
+		code, _, body := ts.postForm(t, "/snippet/edit/1", form)
+
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+		assert.StringContains(t, body, "Tags can only contain")
+	})
+
+	t.Run("Non-existent ID", func(t *testing.T) {
+		code, _, _ := ts.get(t, "/snippet/edit/2")
+
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+
+	t.Run("Not owner", func(t *testing.T) {
+		code, _, _ := ts.get(t, "/snippet/edit/9")
+
+		assert.Equal(t, code, http.StatusNotFound)
+
+		_, _, body := ts.get(t, "/snippet/edit/1")
+
+		form := url.Values{}
+		form.Add("title", "An old silent pond")
+		form.Add("content", "A frog jumps into the pond")
+		form.Add("language", "plaintext")
+		form.Add("visibility", "public")
+		form.Add("csrf_token", extractCSRFToken(t, body))
+		code, _, _ = ts.postForm(t, "/snippet/edit/9", form)
+
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+}
+
+func TestTagView(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantBody string
+	}{
+		{
+			name:     "Tagged",
+			urlPath:  "/tag/haiku",
+			wantBody: "An old silent pond",
+		},
+		{
+			name:     "Mixed case",
+			urlPath:  "/tag/HaiKu",
+			wantBody: "An old silent pond",
+		},
+		{
+			name:     "Unused",
+			urlPath:  "/tag/sql",
+			wantBody: "No snippets have this tag.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, http.StatusOK)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
+
+func TestTagSuggest(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantBody string
+	}{
+		{
+			name:     "Matches",
+			urlPath:  "/tags/suggest?prefix=HA",
+			wantBody: `["haiku","hash"]`,
+		},
+		{
+			name:     "No matches",
+			urlPath:  "/tags/suggest?prefix=x",
+			wantBody: `[]`,
+		},
+		{
+			name:     "Empty prefix",
+			urlPath:  "/tags/suggest",
+			wantBody: `[]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, http.StatusOK)
+			assert.Equal(t, headers.Get("Content-Type"), "application/json")
+			assert.Equal(t, body, tt.wantBody)
+		})
+	}
+}
+
+func TestSnippetComment(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Comments shown", func(t *testing.T) {
+		code, _, body := ts.get(t, "/snippet/view/Bq3xT7nLwP")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "<p>Why a <strong>frog</strong>?</p>")
+		assert.StringContains(t, body, "on <a href='#L1'>L1</a>")
+		assert.StringContains(t, body, "<div class='comment reply' id='comment-2'>")
+		assert.StringContains(t, body, "<a href='/user/login'>Log in</a> to comment.")
+	})
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippet/view/Bq3xT7nLwP")
+	assert.StringContains(t, body, "<a href='/comment/edit/1'>Edit</a>")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		body         string
+		line         string
+		parentID     string
+		wantCode     int
+		wantLocation string
+		wantBody     string
+	}{
+		{
+			name:         "Valid",
+			urlPath:      "/snippet/comment/Bq3xT7nLwP",
+			body:         "Nice.",
+			line:         "L1",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/Bq3xT7nLwP#comment-3",
+		},
+		{
+			name:         "Reply",
+			urlPath:      "/snippet/comment/Bq3xT7nLwP",
+			body:         "Agreed.",
+			parentID:     "1",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/Bq3xT7nLwP#comment-3",
+		},
+		{
+			name:     "Blank",
+			urlPath:  "/snippet/comment/Bq3xT7nLwP",
+			body:     " ",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field cannot be blank",
+		},
+		{
+			name:     "Too long",
+			urlPath:  "/snippet/comment/Bq3xT7nLwP",
+			body:     strings.Repeat("a", maxCommentLength+1),
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field cannot be more than 5000 characters long",
+		},
+		{
+			name:     "No such line",
+			urlPath:  "/snippet/comment/Bq3xT7nLwP",
+			body:     "Nice.",
+			line:     "L2",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field must be a line of the snippet",
+		},
+		{
+			name:     "No such parent",
+			urlPath:  "/snippet/comment/Bq3xT7nLwP",
+			body:     "Nice.",
+			parentID: "99",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "View limited",
+			urlPath:  "/snippet/comment/Bn8rA4tLeX",
+			body:     "Nice.",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:         "Edit",
+			urlPath:      "/comment/edit/1",
+			body:         "Why a frog, though?",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/Bq3xT7nLwP#comment-1",
+		},
+		{
+			name:     "Edit blank",
+			urlPath:  "/comment/edit/1",
+			body:     "",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field cannot be blank",
+		},
+		{
+			name:     "Edit someone else's",
+			urlPath:  "/comment/edit/2",
+			body:     "Hijacked",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:         "Delete",
+			urlPath:      "/comment/delete/1",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/Bq3xT7nLwP#comments",
+		},
+		{
+			name:     "Delete someone else's",
+			urlPath:  "/comment/delete/2",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("body", tt.body)
+			form.Add("line", tt.line)
+			form.Add("parent_id", tt.parentID)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, body := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+
+	t.Run("Edit form", func(t *testing.T) {
+		code, _, body := ts.get(t, "/comment/edit/1")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "<textarea name='body'>Why a **frog**?</textarea>")
+
+		code, _, _ = ts.get(t, "/comment/edit/2")
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+}
+
+func TestLineExists(t *testing.T) {
+	snippet := &models.Snippet{
+		Language: "go",
+		Content:  "package main\n\nfunc main() {}\n",
+		Files: []*models.File{
+			{Position: 2, Filename: "go.mod", Language: "plaintext", Content: "module hello"},
+			{Position: 3, Filename: "README.md", Language: "markdown", Content: "# Hello"},
+		},
+	}
+
+	tests := []struct {
+		anchor string
+		want   bool
+	}{
+		{anchor: "L1", want: true},
+		{anchor: "L3", want: true},
+		{anchor: "L4", want: false},
+		{anchor: "L0", want: false},
+		{anchor: "file-2-L1", want: true},
+		{anchor: "file-2-L2", want: false},
+		{anchor: "file-3-L1", want: false},
+		{anchor: "file-4-L1", want: false},
+		{anchor: "file-1-L1", want: false},
+		{anchor: "line 1", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.anchor, func(t *testing.T) {
+			assert.Equal(t, lineExists(snippet, tt.anchor), tt.want)
+		})
+	}
+}
+
+func TestSnippetHistory(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/snippet/history/Bq3xT7nLwP")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<td>#1</td>")
+}
+
+func TestSnippetDiff(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Latest changes",
+			urlPath:  "/snippet/diff/Bq3xT7nLwP",
+			wantCode: http.StatusOK,
+			wantBody: "@@ -1 &#43;1 @@",
+		},
+		{
+			name:     "Side by side",
+			urlPath:  "/snippet/diff/Bq3xT7nLwP?from=1&to=2&view=split",
+			wantCode: http.StatusOK,
+			wantBody: "<td class='insert'><pre>An old silent pond...</pre></td>",
+		},
+		{
+			name:     "Same version",
+			urlPath:  "/snippet/diff/Bq3xT7nLwP?from=2&to=2",
+			wantCode: http.StatusOK,
+			wantBody: "The content of these versions is identical.",
+		},
+		{
+			name:     "Non-existent version",
+			urlPath:  "/snippet/diff/Bq3xT7nLwP?from=1&to=3",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Invalid version",
+			urlPath:  "/snippet/diff/Bq3xT7nLwP?from=foo",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/snippet/diff/2",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Numeric ID",
+			urlPath:  "/snippet/diff/1?from=1&to=2",
+			wantCode: http.StatusMovedPermanently,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+
+	t.Run("Patch", func(t *testing.T) {
+		code, headers, body := ts.get(t, "/snippet/patch/Bq3xT7nLwP?from=1&to=2")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, headers.Get("Content-Disposition"), `attachment; filename="snippet-Bq3xT7nLwP-v1-v2.patch"`)
+		assert.Equal(t, body, "--- a/snippet-Bq3xT7nLwP\n+++ b/snippet-Bq3xT7nLwP\n@@ -1 +1 @@\n-An old pond...\n\\ No newline at end of file\n+An old silent pond...\n\\ No newline at end of file\n")
+	})
+}
+
+func TestSnippetTrash(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/account/trash")
+	assert.StringContains(t, body, "First autumn morning")
+
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Delete",
+			urlPath:      "/snippet/delete/1",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/account/trash",
+		},
+		{
+			name:     "Delete non-existent ID",
+			urlPath:  "/snippet/delete/2",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:         "Restore",
+			urlPath:      "/account/trash/restore/3",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/3",
+		},
+		{
+			name:     "Restore snippet not in trash",
+			urlPath:  "/account/trash/restore/1",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:         "Purge",
+			urlPath:      "/account/trash/purge/3",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/account/trash",
+		},
+		{
+			name:     "Purge snippet not in trash",
+			urlPath:  "/account/trash/purge/1",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", validCSRFToken)
+
+			code, headers, _ := ts.postForm(t, tt.urlPath, form)
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+		})
+	}
+}
+
+func TestSearch(t *testing.T) {
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Empty query",
+			urlPath:  "/search",
+			wantCode: http.StatusOK,
+			wantBody: "<form action='/search' method='GET' class='search' novalidate>",
+		},
+		{
+			name:     "Match",
+			urlPath:  "/search?q=pond",
+			wantCode: http.StatusOK,
+			wantBody: "An old silent <mark>pond</mark>...",
+		},
+		{
+			name:     "No match",
+			urlPath:  "/search?q=frog",
+			wantCode: http.StatusOK,
+			wantBody: "No snippets match your search.",
+		},
+		{
+			name:     "Syntax error",
+			urlPath:  "/search?q=auther:alice",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Invalid search: unknown filter &#34;auther:&#34; at character 1",
+		},
+		{
+			name:     "Query too long",
+			urlPath:  "/search?q=" + strings.Repeat("a", 201),
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field cannot be more than 200 characters long",
+		},
+	}
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			code, _, body := ts.get(t, tt.urlPath)
@@ -5,21 +5,238 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"regexp"
 	"runtime/debug"
+	"strconv"
+	"strings"
 	"time"
 
+	"snippetbox.davc.io/internal/highlight"
+	"snippetbox.davc.io/internal/models"
+
 	"github.com/go-playground/form/v4"
+	"github.com/julienschmidt/httprouter"
 	"github.com/justinas/nosurf"
 )
 
 // Common dynamic data.
 func (self *application) newTemplateData(r *http.Request) *templateData {
-	return &templateData{
+	data := &templateData{
 		CurrentYear:     time.Now().Year(),
 		Flash:           self.sessionManager.PopString(r.Context(), "flash"),
 		IsAuthenticated: self.isAuthenticated(r),
 		CSRFToken:       nosurf.Token(r),
 	}
+
+	if data.IsAuthenticated {
+		data.AuthenticatedUserID = self.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+	}
+
+	return data
+}
+
+// Number of snippets per listing page, which the size query parameter can
+// override up to models.MaxPageSize.
+func (self *application) readPageSize(r *http.Request) int {
+	size, err := strconv.Atoi(r.URL.Query().Get("size"))
+	if err != nil || size < 1 {
+		size = self.pageSize
+	}
+
+	return min(size, models.MaxPageSize)
+}
+
+// Links to the pages on either side of page. They keep the current query
+// string and only replace the cursor.
+func pageLinks(r *http.Request, page *models.SnippetPage) (prev, next string) {
+	if page.Prev != "" {
+		prev = withQuery(r, "cursor", page.Prev)
+	}
+	if page.Next != "" {
+		next = withQuery(r, "cursor", page.Next)
+	}
+
+	return prev, next
+}
+
+// The current URL with one query parameter replaced.
+func withQuery(r *http.Request, key, value string) string {
+	query := r.URL.Query()
+	query.Set(key, value)
+
+	return r.URL.Path + "?" + query.Encode()
+}
+
+// Split a comma-separated list of tags, normalising them to lowercase and
+// dropping blanks and duplicates.
+func splitTags(s string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+
+	for _, tag := range strings.Split(s, ",") {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+
+	return tags
+}
+
+// Read the :id named parameter, which must be a positive integer.
+func readIDParam(r *http.Request) (int, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid id parameter")
+	}
+
+	return id, nil
+}
+
+// Look up the snippet named by the id URL parameter for showing it, writing a
+// response and returning false when there's none the viewer may see. Viewers
+// other than the owner get the unlock form for password protected snippets
+// they haven't unlocked yet.
+func (self *application) readSnippetParam(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, ok := self.findSnippet(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if self.isLocked(r, snippet) {
+		data := self.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = snippetUnlockForm{}
+		self.render(w, http.StatusForbidden, "unlock.html", data)
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// Like readSnippetParam, for pages showing a snippet's revisions. Those would
+// give away a view-limited snippet without using up a view, so only its owner
+// may see them.
+func (self *application) readHistoryParam(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, ok := self.readSnippetParam(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if snippet.ViewsLeft != nil && !self.isOwner(r, snippet) {
+		self.notFound(w)
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// Use up a view of a view-limited snippet, its owner's included, updating its
+// ViewsLeft. When ok is false the snippet has no views left, or the view
+// couldn't be recorded, and a response has already been written.
+func (self *application) consumeView(w http.ResponseWriter, snippet *models.Snippet) bool {
+	if snippet.ViewsLeft == nil {
+		return true
+	}
+
+	left, err := self.snippets.ConsumeView(snippet.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			self.notFound(w)
+		} else {
+			self.serverError(w, err)
+		}
+		return false
+	}
+
+	snippet.ViewsLeft = &left
+	return true
+}
+
+// Look up the snippet named by the id URL parameter, writing an error
+// response and returning false when there's none the viewer may see. The
+// parameter is normally a slug. Numeric IDs from links made before slugs
+// existed are redirected to the slug URL when the snippet is public or the
+// viewer's own; anything else is reported as missing so that counting IDs
+// can't uncover unlisted snippets.
+func (self *application) findSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	param := httprouter.ParamsFromContext(r.Context()).ByName("id")
+	viewerID := self.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	var snippet *models.Snippet
+	var err error
+
+	numeric := strings.Trim(param, "0123456789") == ""
+	if numeric {
+		id, idErr := readIDParam(r)
+		if idErr != nil {
+			self.notFound(w)
+			return nil, false
+		}
+		snippet, err = self.snippets.Get(id, viewerID)
+	} else {
+		snippet, err = self.snippets.GetBySlug(param, viewerID)
+	}
+
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			self.notFound(w)
+		} else {
+			self.serverError(w, err)
+		}
+		return nil, false
+	}
+
+	if numeric {
+		if snippet.Visibility != models.VisibilityPublic && snippet.UserID != viewerID {
+			self.notFound(w)
+			return nil, false
+		}
+
+		// Swap the ID for the slug, keeping any path that follows it.
+		u := *r.URL
+		segments := strings.Split(u.Path, "/")
+		for i := range segments {
+			if segments[i] == param {
+				segments[i] = snippet.Slug
+				break
+			}
+		}
+		u.Path = strings.Join(segments, "/")
+		http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// Session key recording that the session unlocked a protected snippet.
+func unlockedKey(snippetID int) string {
+	return fmt.Sprintf("unlockedSnippet:%d", snippetID)
+}
+
+// Report whether the snippet's password is needed before the current user
+// may see it.
+func (self *application) isLocked(r *http.Request, snippet *models.Snippet) bool {
+	if !snippet.Protected {
+		return false
+	}
+
+	if self.isOwner(r, snippet) {
+		return false
+	}
+
+	return !self.sessionManager.GetBool(r.Context(), unlockedKey(snippet.ID))
+}
+
+// Report whether the snippet belongs to the authenticated user.
+func (self *application) isOwner(r *http.Request, snippet *models.Snippet) bool {
+	return snippet.UserID == self.sessionManager.GetInt(r.Context(), "authenticatedUserID")
 }
 
 func (self *application) decodePostForm(r *http.Request, dst any) error {
@@ -28,7 +245,12 @@ func (self *application) decodePostForm(r *http.Request, dst any) error {
 		return err
 	}
 
-	err = self.formDecoder.Decode(dst, r.PostForm)
+	return self.decodeForm(r, dst)
+}
+
+// Decode the parsed r.PostForm into dst.
+func (self *application) decodeForm(r *http.Request, dst any) error {
+	err := self.formDecoder.Decode(dst, r.PostForm)
 	if err != nil {
 		var invalidDecoderError *form.InvalidDecoderError
 		if errors.As(err, &invalidDecoderError) {
@@ -97,3 +319,145 @@ func (self *application) clientError(w http.ResponseWriter, status int) {
 func (self *application) notFound(w http.ResponseWriter) {
 	self.clientError(w, http.StatusNotFound)
 }
+
+// Spell out a duration in the largest of weeks, days, hours or minutes that
+// divides it exactly, such as "3 days".
+func formatDuration(d time.Duration) string {
+	units := []struct {
+		name string
+		size time.Duration
+	}{
+		{"week", 7 * 24 * time.Hour},
+		{"day", 24 * time.Hour},
+		{"hour", time.Hour},
+		{"minute", time.Minute},
+	}
+
+	n, name := int64(d/time.Minute), "minute"
+	for _, u := range units {
+		if d%u.size == 0 {
+			n, name = int64(d/u.size), u.name
+			break
+		}
+	}
+
+	if n == 1 {
+		return "1 " + name
+	}
+	return fmt.Sprintf("%d %ss", n, name)
+}
+
+// Write snippet content as plain text. The browser is told not to sniff it as
+// anything else, and to treat it as a sandboxed document that may load and run
+// nothing, so content that looks like HTML can't do any harm when opened
+// directly.
+func writeRaw(w http.ResponseWriter, content string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; sandbox")
+
+	w.Write([]byte(content))
+}
+
+// Look up the content of a snippet's file by its filename.
+func fileContent(snippet *models.Snippet, filename string) (string, bool) {
+	if filename == snippet.Filename {
+		return snippet.Content, filename != ""
+	}
+
+	for _, f := range snippet.Files {
+		if f.Filename == filename {
+			return f.Content, true
+		}
+	}
+
+	return "", false
+}
+
+// Name a downloaded snippet after its first file or, when that has no name,
+// after its title with the extension of its language.
+func downloadFilename(snippet *models.Snippet) string {
+	if snippet.Filename != "" {
+		return snippet.Filename
+	}
+
+	name := titleSlug(snippet.Title)
+	if name == "" {
+		name = "snippet-" + snippet.Slug
+	}
+
+	return name + highlight.Extension(snippet.Language)
+}
+
+// Reduce a title to lowercase letters, digits and dashes, for use in
+// filenames. It's at most about 50 characters long, and may be empty.
+func titleSlug(title string) string {
+	var sb strings.Builder
+	dash := false
+
+	for _, c := range strings.ToLower(title) {
+		if 'a' <= c && c <= 'z' || '0' <= c && c <= '9' {
+			if dash && sb.Len() > 0 {
+				sb.WriteByte('-')
+			}
+			sb.WriteRune(c)
+			dash = false
+		} else {
+			dash = true
+		}
+
+		if sb.Len() >= 50 {
+			break
+		}
+	}
+
+	return sb.String()
+}
+
+// Anchors of lines, "L12" in a snippet's first file or "file-2-L12" in the
+// others.
+var lineAnchorRX = regexp.MustCompile(`^(?:file-([0-9]+)-)?L([0-9]+)$`)
+
+// Report whether anchor names a line of one of the snippet's files. Markdown
+// is shown as a document, without line anchors.
+func lineExists(snippet *models.Snippet, anchor string) bool {
+	m := lineAnchorRX.FindStringSubmatch(anchor)
+	if m == nil {
+		return false
+	}
+
+	line, err := strconv.Atoi(m[2])
+	if err != nil || line < 1 {
+		return false
+	}
+
+	language, content := snippet.Language, snippet.Content
+
+	if m[1] != "" {
+		position, err := strconv.Atoi(m[1])
+		if err != nil {
+			return false
+		}
+
+		found := false
+		for _, f := range snippet.Files {
+			if f.Position == position {
+				language, content, found = f.Language, f.Content, true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if language == highlight.Markdown {
+		return false
+	}
+
+	lines := strings.Count(content, "\n")
+	if !strings.HasSuffix(content, "\n") {
+		lines++
+	}
+
+	return line <= lines
+}
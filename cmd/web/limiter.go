@@ -0,0 +1,72 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Counts failed attempts to unlock password protected snippets, so that a
+// snippet's password can't be guessed by brute force. Each snippet allows
+// max failures per window, after which every attempt is refused until the
+// window has passed.
+type unlockLimiter struct {
+	mu       sync.Mutex
+	max      int
+	window   time.Duration
+	failures map[int]*failureCount
+}
+
+type failureCount struct {
+	count int
+	start time.Time
+}
+
+func newUnlockLimiter(max int, window time.Duration) *unlockLimiter {
+	return &unlockLimiter{
+		max:      max,
+		window:   window,
+		failures: map[int]*failureCount{},
+	}
+}
+
+// Reserve an attempt to unlock the snippet, reporting whether one was left.
+// Attempts are counted as failures before the password is checked, which
+// takes a while, so that guesses sent all at once can't slip in before the
+// first of them has failed. Refund the attempt if the password was right.
+func (self *unlockLimiter) reserve(snippetID int) bool {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	now := time.Now()
+
+	// Forget windows that have passed so the map doesn't keep growing.
+	for id, f := range self.failures {
+		if now.Sub(f.start) >= self.window {
+			delete(self.failures, id)
+		}
+	}
+
+	f, ok := self.failures[snippetID]
+	if !ok {
+		f = &failureCount{start: now}
+		self.failures[snippetID] = f
+	}
+
+	if f.count >= self.max {
+		return false
+	}
+
+	f.count++
+	return true
+}
+
+// Give back an attempt reserved for the snippet that didn't fail.
+func (self *unlockLimiter) refund(snippetID int) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	f, ok := self.failures[snippetID]
+	if ok && f.count > 0 {
+		f.count--
+	}
+}
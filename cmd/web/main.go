@@ -3,11 +3,15 @@ package main
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"flag"
 	"html/template"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"snippetbox.davc.io/internal/models"
@@ -23,9 +27,20 @@ type application struct {
 	infoLog        *log.Logger
 	snippets       models.SnippetModelInterface
 	users          models.UserModelInterface
+	tags           models.TagModelInterface
+	stars          models.StarModelInterface
+	comments       models.CommentModelInterface
+	collections    models.CollectionModelInterface
+	views          models.ViewModelInterface
+	viewRecorder   *viewRecorder
 	templateCache  map[string]*template.Template
 	formDecoder    *form.Decoder
 	sessionManager *scs.SessionManager
+	unlockLimiter  *unlockLimiter
+	pageSize       int
+	minExpiry      time.Duration
+	maxExpiry      time.Duration
+	frameAncestors []string
 	debug          bool
 }
 
@@ -33,12 +48,24 @@ func main() {
 	addr := flag.String("addr", ":4000", "HTTP network address")
 	dsn := flag.String("dsn", os.Getenv("POSTGRES_DSN"), "PostgreSQL data source name")
 	debug := flag.Bool("debug", false, "Enable debug mode")
+	pageSize := flag.Int("page-size", 10, "Default number of snippets per page (at most 100)")
+	unlockAttempts := flag.Int("unlock-attempts", 5, "Failed password attempts allowed per snippet every 15 minutes")
+	minExpiry := flag.Duration("min-expiry", 5*time.Minute, "Shortest time a new snippet may be kept")
+	maxExpiry := flag.Duration("max-expiry", 0, "Longest time a new snippet may be kept (0 for no limit, which allows snippets that never expire)")
+	reapInterval := flag.Duration("reap-interval", 10*time.Minute, "How often to delete expired snippets and sessions (0 to never)")
+	reapGrace := flag.Duration("reap-grace", 24*time.Hour, "How long to keep snippets after they expire")
+	embedAncestors := flag.String("embed-ancestors", "", "Space separated sources allowed to frame embedded snippets, such as \"https://wiki.example.com\" (none if empty)")
 
 	flag.Parse()
 
 	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
 	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
 
+	frameAncestors, err := parseFrameAncestors(*embedAncestors)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
 	db, err := openDB(*dsn)
 	if err != nil {
 		errorLog.Fatal(err)
@@ -54,17 +81,40 @@ func main() {
 	formDecoder := form.NewDecoder()
 
 	sessionManager := scs.New()
-	sessionManager.Store = pgxstore.New(db)
+	// The reaper deletes expired sessions, unless it's turned off.
+	if *reapInterval > 0 {
+		sessionManager.Store = pgxstore.NewWithCleanupInterval(db, 0)
+	} else {
+		sessionManager.Store = pgxstore.New(db)
+	}
 	sessionManager.Lifetime = 12 * time.Hour
 
+	viewModel := &models.ViewModel{DB: db}
+
+	viewRecorder, err := newViewRecorder(viewModel, viewBufferSize, errorLog)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
 	app := &application{
 		errorLog:       errorLog,
 		infoLog:        infoLog,
 		snippets:       &models.SnippetModel{DB: db},
 		users:          &models.UserModel{DB: db},
+		tags:           &models.TagModel{DB: db},
+		stars:          &models.StarModel{DB: db},
+		comments:       &models.CommentModel{DB: db},
+		collections:    &models.CollectionModel{DB: db},
+		views:          viewModel,
+		viewRecorder:   viewRecorder,
 		templateCache:  templateCache,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
+		unlockLimiter:  newUnlockLimiter(*unlockAttempts, 15*time.Minute),
+		pageSize:       *pageSize,
+		minExpiry:      *minExpiry,
+		maxExpiry:      *maxExpiry,
+		frameAncestors: frameAncestors,
 		debug:          *debug,
 	}
 
@@ -80,9 +130,70 @@ func main() {
 		WriteTimeout: 10 * time.Second,
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var wg sync.WaitGroup
+
+	if *reapInterval > 0 {
+		r := &reaper{
+			model:     &models.ReaperModel{DB: db},
+			interval:  *reapInterval,
+			grace:     *reapGrace,
+			batchSize: reapBatchSize,
+			infoLog:   infoLog,
+			errorLog:  errorLog,
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.run(ctx)
+		}()
+	}
+
+	// The view recorder keeps going until the server has shut down, so it
+	// saves the views of requests that were in flight.
+	viewsCtx, stopViews := context.WithCancel(context.Background())
+	defer stopViews()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		viewRecorder.run(viewsCtx, viewBatchSize, viewFlushInterval)
+	}()
+
+	// Stop accepting connections on a signal, giving requests in flight a
+	// little while to finish.
+	shutdownErr := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		infoLog.Print("Shutting down")
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		shutdownErr <- srv.Shutdown(shutdownCtx)
+	}()
+
 	infoLog.Printf("Starting server on %s", *addr)
 	err = srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
-	errorLog.Fatal(err)
+	if !errors.Is(err, http.ErrServerClosed) {
+		errorLog.Fatal(err)
+	}
+
+	err = <-shutdownErr
+	if err != nil {
+		errorLog.Print(err)
+	}
+
+	stopViews()
+
+	// Let the reaper finish the batch it's on and the view recorder save the
+	// views it holds.
+	wg.Wait()
+
+	infoLog.Print("Stopped server")
 }
 
 func openDB(dsn string) (*pgxpool.Pool, error) {
@@ -2,25 +2,87 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/justinas/nosurf"
 )
 
+// The security headers set on a response.
+type securityPolicy struct {
+	contentSecurity string
+	// X-Frame-Options, which is left out when empty.
+	frameOptions string
+}
+
+// The policy for every page, unless its route sets another.
+var defaultPolicy = securityPolicy{
+	contentSecurity: "default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com",
+	frameOptions:    "deny",
+}
+
 // Set security headers.
 func secureHeaders(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Security-Policy",
-			"default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com")
+	return withPolicy(defaultPolicy)(next)
+}
 
-		w.Header().Set("Referrer-Policy", "origin-when-cross-origin")
-		w.Header().Set("X-Content-Type-Options", "nosniff")
-		w.Header().Set("X-Frame-Options", "deny")
-		w.Header().Set("X-XSS-Protection", "0")
+// Set security headers following a policy. Wrapping a route in this replaces
+// the headers secureHeaders set for the whole site.
+func withPolicy(policy securityPolicy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Security-Policy", policy.contentSecurity)
+
+			w.Header().Set("Referrer-Policy", "origin-when-cross-origin")
+			w.Header().Set("X-Content-Type-Options", "nosniff")
+			if policy.frameOptions != "" {
+				w.Header().Set("X-Frame-Options", policy.frameOptions)
+			} else {
+				w.Header().Del("X-Frame-Options")
+			}
+			w.Header().Set("X-XSS-Protection", "0")
 
-		next.ServeHTTP(w, r)
-	})
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// The policy for embedded snippets, which only the given sources may frame.
+// X-Frame-Options can't name more than one site, so it's dropped in favour of
+// frame-ancestors, which browsers follow instead when both are set. With no
+// sources nobody may frame them.
+func embedPolicy(frameAncestors []string) securityPolicy {
+	if len(frameAncestors) == 0 {
+		return securityPolicy{
+			contentSecurity: defaultPolicy.contentSecurity + "; frame-ancestors 'none'",
+			frameOptions:    "deny",
+		}
+	}
+
+	return securityPolicy{
+		contentSecurity: defaultPolicy.contentSecurity + "; frame-ancestors " + strings.Join(frameAncestors, " "),
+	}
+}
+
+// Split a space separated list of frame-ancestors sources, such as
+// "'self' https://wiki.example.com", rejecting anything that would end the
+// directive early or isn't a source.
+func parseFrameAncestors(s string) ([]string, error) {
+	sources := strings.Fields(s)
+
+	for _, source := range sources {
+		if source == "'self'" {
+			continue
+		}
+
+		if strings.ContainsAny(source, ";,'\"") {
+			return nil, fmt.Errorf("invalid frame ancestor %q", source)
+		}
+	}
+
+	return sources, nil
 }
 
 // Log HTTP requests.
@@ -75,10 +137,24 @@ func noSurf(next http.Handler) http.Handler {
 		Path:     "/",
 		Secure:   true,
 	})
+	csrfHandler.SetFailureHandler(http.HandlerFunc(csrfFailure))
 
 	return csrfHandler
 }
 
+// Answer requests that fail the CSRF check. nosurf parses the form to find
+// the token, so a body over the limit set by limitBody fails here before any
+// handler could report it, and is told apart from a missing token.
+func csrfFailure(w http.ResponseWriter, r *http.Request) {
+	if bodyTooLarge(r) {
+		msg := fmt.Sprintf("The form is too large to send. Uploads can be at most %d KB each, and %d files altogether.", maxUploadSize>>10, maxFiles)
+		http.Error(w, msg, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+}
+
 func (self *application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		id := self.sessionManager.GetInt(r.Context(), "authenticatedUserID")
@@ -111,3 +187,24 @@ func (self *application) authenticate(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// Refuse request bodies larger than n bytes. Reading past the limit fails,
+// so the form can't be parsed.
+func limitBody(n int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Report whether reading the request's body ran past the limit set by
+// limitBody. The body keeps failing once it has, so this can still be told
+// after the form was parsed.
+func bodyTooLarge(r *http.Request) bool {
+	_, err := r.Body.Read(make([]byte, 1))
+
+	var maxBytesError *http.MaxBytesError
+	return errors.As(err, &maxBytesError)
+}
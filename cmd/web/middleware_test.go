@@ -5,6 +5,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"snippetbox.davc.io/internal/assert"
@@ -58,3 +59,88 @@ func TestSecureHeaders(t *testing.T) {
 	bytes.TrimSpace(body)
 	assert.Equal(t, string(body), "OK")
 }
+
+func TestEmbedPolicy(t *testing.T) {
+	tests := []struct {
+		name             string
+		frameAncestors   []string
+		wantCSP          string
+		wantFrameOptions string
+	}{
+		{
+			name:             "Allowed",
+			frameAncestors:   []string{"'self'", "https://wiki.example.com"},
+			wantCSP:          "default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com; frame-ancestors 'self' https://wiki.example.com",
+			wantFrameOptions: "",
+		},
+		{
+			name:             "None",
+			wantCSP:          "default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com; frame-ancestors 'none'",
+			wantFrameOptions: "deny",
+		},
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+
+			r, err := http.NewRequest(http.MethodGet, "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The route's policy replaces the one set for the whole site.
+			secureHeaders(withPolicy(embedPolicy(tt.frameAncestors))(next)).ServeHTTP(rr, r)
+
+			rs := rr.Result()
+			defer rs.Body.Close()
+
+			assert.Equal(t, rs.Header.Get("Content-Security-Policy"), tt.wantCSP)
+			assert.Equal(t, rs.Header.Get("X-Frame-Options"), tt.wantFrameOptions)
+			assert.Equal(t, rs.Header.Get("X-Content-Type-Options"), "nosniff")
+		})
+	}
+}
+
+func TestParseFrameAncestors(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Empty",
+			s:    "",
+			want: "",
+		},
+		{
+			name: "Sources",
+			s:    " 'self'  https://wiki.example.com *.example.org ",
+			want: "'self' https://wiki.example.com *.example.org",
+		},
+		{
+			name:    "Other directive",
+			s:       "https://wiki.example.com; script-src *",
+			wantErr: true,
+		},
+		{
+			name:    "Keyword",
+			s:       "'unsafe-inline'",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sources, err := parseFrameAncestors(tt.s)
+
+			assert.Equal(t, err != nil, tt.wantErr)
+			assert.Equal(t, strings.Join(sources, " "), tt.want)
+		})
+	}
+}
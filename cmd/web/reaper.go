@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"snippetbox.davc.io/internal/models"
+)
+
+// Most rows a single delete statement removes.
+const reapBatchSize = 500
+
+// Periodically deletes expired snippets and data left behind by them, along
+// with expired sessions. Snippets are kept for a grace period after they
+// expire before they're removed for good.
+type reaper struct {
+	model     models.ReaperModelInterface
+	interval  time.Duration
+	grace     time.Duration
+	batchSize int
+	infoLog   *log.Logger
+	errorLog  *log.Logger
+}
+
+// Reap once straight away and then every interval until ctx is cancelled.
+func (self *reaper) run(ctx context.Context) {
+	ticker := time.NewTicker(self.interval)
+	defer ticker.Stop()
+
+	for {
+		self.reap(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Delete everything due in batches, logging how many rows were removed.
+// Snippets go first so that the tags they leave behind are removed too.
+func (self *reaper) reap(ctx context.Context) {
+	snippets, err := self.purge(ctx, func(limit int) (int, error) {
+		return self.model.PurgeSnippets(self.grace, limit)
+	})
+	if err != nil {
+		self.errorLog.Printf("reaper: purging snippets: %v", err)
+	}
+
+	tags, err := self.purge(ctx, self.model.PurgeTags)
+	if err != nil {
+		self.errorLog.Printf("reaper: purging tags: %v", err)
+	}
+
+	sessions, err := self.purge(ctx, self.model.PurgeSessions)
+	if err != nil {
+		self.errorLog.Printf("reaper: purging sessions: %v", err)
+	}
+
+	self.infoLog.Printf("Reaper removed %d snippets, %d tags and %d sessions", snippets, tags, sessions)
+}
+
+// Call purge with the batch size until a batch comes back short, ctx is
+// cancelled or an error occurs, and return the total number of rows removed.
+func (self *reaper) purge(ctx context.Context, purge func(limit int) (int, error)) (int, error) {
+	total := 0
+
+	for ctx.Err() == nil {
+		n, err := purge(self.batchSize)
+		total += n
+		if err != nil || n < self.batchSize {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"snippetbox.davc.io/internal/assert"
+)
+
+// A reaper model with a fixed number of rows due for deletion.
+type fakeReaperModel struct {
+	snippets int
+	calls    int
+	grace    time.Duration
+}
+
+func (m *fakeReaperModel) PurgeSnippets(grace time.Duration, limit int) (int, error) {
+	m.calls++
+	m.grace = grace
+	n := min(m.snippets, limit)
+	m.snippets -= n
+	return n, nil
+}
+
+func (m *fakeReaperModel) PurgeTags(limit int) (int, error) {
+	return 0, errors.New("connection refused")
+}
+
+func (m *fakeReaperModel) PurgeSessions(limit int) (int, error) {
+	return 3, nil
+}
+
+func TestReaperReap(t *testing.T) {
+	model := &fakeReaperModel{snippets: 25}
+
+	var infoLog, errorLog bytes.Buffer
+
+	r := &reaper{
+		model:     model,
+		interval:  time.Minute,
+		grace:     time.Hour,
+		batchSize: 10,
+		infoLog:   log.New(&infoLog, "", 0),
+		errorLog:  log.New(&errorLog, "", 0),
+	}
+
+	r.reap(context.Background())
+
+	assert.Equal(t, model.snippets, 0)
+	assert.Equal(t, model.calls, 3)
+	assert.Equal(t, model.grace, time.Hour)
+	assert.Equal(t, infoLog.String(), "Reaper removed 25 snippets, 0 tags and 3 sessions\n")
+	assert.StringContains(t, errorLog.String(), "reaper: purging tags: connection refused")
+
+	t.Run("Cancelled", func(t *testing.T) {
+		model := &fakeReaperModel{snippets: 25}
+		r.model = model
+		r.infoLog = log.New(io.Discard, "", 0)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		r.run(ctx)
+
+		assert.Equal(t, model.calls, 0)
+	})
+}
@@ -22,12 +22,31 @@ func (self *application) routes() http.Handler {
 	// For testing
 	router.HandlerFunc(http.MethodGet, "/ping", ping)
 
+	// Autocomplete doesn't need a session.
+	router.HandlerFunc(http.MethodGet, "/tags/suggest", self.tagSuggest)
+
+	// Embedded snippets are framed by other sites, where the session cookie
+	// isn't sent, so they go without one.
+	embed := alice.New(withPolicy(embedPolicy(self.frameAncestors)))
+	router.Handler(http.MethodGet, "/embed/:id", embed.ThenFunc(self.snippetEmbed))
+
 	// For session management, create a new middleware chain.
 	dynamic := alice.New(self.sessionManager.LoadAndSave, noSurf, self.authenticate)
 
 	router.Handler(http.MethodGet, "/", dynamic.ThenFunc(self.home))
 	router.Handler(http.MethodGet, "/about", dynamic.ThenFunc(self.about))
+	router.Handler(http.MethodGet, "/search", dynamic.ThenFunc(self.search))
+	router.Handler(http.MethodGet, "/tag/:name", dynamic.ThenFunc(self.tagView))
 	router.Handler(http.MethodGet, "/snippet/view/:id", dynamic.ThenFunc(self.snippetView))
+	router.Handler(http.MethodPost, "/snippet/unlock/:id", dynamic.ThenFunc(self.snippetUnlockPost))
+	router.Handler(http.MethodGet, "/snippet/raw/:id", dynamic.ThenFunc(self.snippetRaw))
+	router.Handler(http.MethodGet, "/snippet/raw/:id/:file", dynamic.ThenFunc(self.snippetRawFile))
+	router.Handler(http.MethodGet, "/snippet/download/:id", dynamic.ThenFunc(self.snippetDownload))
+	router.Handler(http.MethodGet, "/snippet/history/:id", dynamic.ThenFunc(self.snippetHistory))
+	router.Handler(http.MethodGet, "/snippet/diff/:id", dynamic.ThenFunc(self.snippetDiff))
+	router.Handler(http.MethodGet, "/snippet/patch/:id", dynamic.ThenFunc(self.snippetPatch))
+	router.Handler(http.MethodGet, "/collection/:id", dynamic.ThenFunc(self.collectionView))
+	router.Handler(http.MethodGet, "/collection/:id/download", dynamic.ThenFunc(self.collectionDownload))
 	router.Handler(http.MethodGet, "/user/signup", dynamic.ThenFunc(self.userSignup))
 	router.Handler(http.MethodPost, "/user/signup", dynamic.ThenFunc(self.userSignupPost))
 	router.Handler(http.MethodGet, "/user/login", dynamic.ThenFunc(self.userLogin))
@@ -36,9 +55,36 @@ func (self *application) routes() http.Handler {
 	protected := dynamic.Append(self.requireAuthentication)
 
 	router.Handler(http.MethodGet, "/snippet/create", protected.ThenFunc(self.snippetCreate))
-	router.Handler(http.MethodPost, "/snippet/create", protected.ThenFunc(self.snippetCreatePost))
+	// The body limit comes before the session and CSRF middleware, which
+	// parse the form.
+	router.Handler(http.MethodPost, "/snippet/create", alice.New(limitBody(maxUploadRequest)).Extend(protected).ThenFunc(self.snippetCreatePost))
+	router.Handler(http.MethodGet, "/snippet/share/:id", protected.ThenFunc(self.snippetShare))
+	router.Handler(http.MethodGet, "/snippet/stats/:id", protected.ThenFunc(self.snippetStats))
+	router.Handler(http.MethodPost, "/snippet/fork/:id", protected.ThenFunc(self.snippetForkPost))
+	router.Handler(http.MethodPost, "/snippet/star/:id", protected.ThenFunc(self.snippetStarPost))
+	router.Handler(http.MethodPost, "/snippet/unstar/:id", protected.ThenFunc(self.snippetUnstarPost))
+	router.Handler(http.MethodPost, "/snippet/comment/:id", protected.ThenFunc(self.snippetCommentPost))
+	router.Handler(http.MethodPost, "/snippet/collect/:id", protected.ThenFunc(self.snippetCollectPost))
+	router.Handler(http.MethodGet, "/comment/edit/:id", protected.ThenFunc(self.commentEdit))
+	router.Handler(http.MethodPost, "/comment/edit/:id", protected.ThenFunc(self.commentEditPost))
+	router.Handler(http.MethodPost, "/comment/delete/:id", protected.ThenFunc(self.commentDeletePost))
+	router.Handler(http.MethodGet, "/snippet/edit/:id", protected.ThenFunc(self.snippetEdit))
+	router.Handler(http.MethodPost, "/snippet/edit/:id", protected.ThenFunc(self.snippetEditPost))
+	router.Handler(http.MethodPost, "/snippet/delete/:id", protected.ThenFunc(self.snippetDeletePost))
 	router.Handler(http.MethodPost, "/user/logout", protected.ThenFunc(self.userLogoutPost))
 	router.Handler(http.MethodGet, "/account/view", protected.ThenFunc(self.accountView))
+	router.Handler(http.MethodGet, "/account/stars", protected.ThenFunc(self.accountStars))
+	router.Handler(http.MethodGet, "/account/collections", protected.ThenFunc(self.accountCollections))
+	router.Handler(http.MethodGet, "/account/collections/create", protected.ThenFunc(self.collectionCreate))
+	router.Handler(http.MethodPost, "/account/collections/create", protected.ThenFunc(self.collectionCreatePost))
+	router.Handler(http.MethodGet, "/collection/:id/edit", protected.ThenFunc(self.collectionEdit))
+	router.Handler(http.MethodPost, "/collection/:id/edit", protected.ThenFunc(self.collectionEditPost))
+	router.Handler(http.MethodPost, "/collection/:id/delete", protected.ThenFunc(self.collectionDeletePost))
+	router.Handler(http.MethodPost, "/collection/:id/remove", protected.ThenFunc(self.collectionRemovePost))
+	router.Handler(http.MethodPost, "/collection/:id/move", protected.ThenFunc(self.collectionMovePost))
+	router.Handler(http.MethodGet, "/account/trash", protected.ThenFunc(self.accountTrash))
+	router.Handler(http.MethodPost, "/account/trash/restore/:id", protected.ThenFunc(self.accountTrashRestorePost))
+	router.Handler(http.MethodPost, "/account/trash/purge/:id", protected.ThenFunc(self.accountTrashPurgePost))
 	router.Handler(http.MethodGet, "/account/password/update", protected.ThenFunc(self.accountPasswordUpdate))
 	router.Handler(http.MethodPost, "/account/password/update", protected.ThenFunc(self.accountPasswordUpdatePost))
 
@@ -4,22 +4,44 @@ import (
 	"html/template"
 	"io/fs"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"snippetbox.davc.io/internal/diff"
+	"snippetbox.davc.io/internal/highlight"
 	"snippetbox.davc.io/internal/models"
 	"snippetbox.davc.io/ui"
 )
 
 // A holding structure for the dynamic data to pass to HTML templates.
 type templateData struct {
-	CurrentYear     int
-	Snippet         *models.Snippet
-	Snippets        []*models.Snippet
-	Form            any
-	Flash           string
-	IsAuthenticated bool
-	CSRFToken       string
-	User            *models.User
+	CurrentYear         int
+	Snippet             *models.Snippet
+	Snippets            []*models.Snippet
+	Tag                 string
+	Tags                []*models.TagCount
+	SearchResults       []*models.SearchResult
+	PrevPage            string
+	NextPage            string
+	Revisions           []*models.Revision
+	FromRevision        *models.Revision
+	ToRevision          *models.Revision
+	Hunks               []diff.Hunk
+	DailyViews          []*models.DailyViews
+	Referrers           []*models.ReferrerCount
+	Comments            []*models.Comment
+	Comment             *models.Comment
+	Collection          *models.Collection
+	Collections         []*models.Collection
+	SideBySide          bool
+	Form                any
+	Flash               string
+	ShareURL            string
+	Starred             bool
+	IsAuthenticated     bool
+	AuthenticatedUserID int
+	CSRFToken           string
+	User                *models.User
 }
 
 // Custom template function.
@@ -31,9 +53,28 @@ func humanDate(t time.Time) string {
 	return t.UTC().Format("02 Jan 2006 at 15:04")
 }
 
+// HTML-escape a search headline and turn its highlight markers into <mark>
+// elements.
+func markMatches(headline string) template.HTML {
+	escaped := template.HTMLEscapeString(headline)
+	escaped = strings.ReplaceAll(escaped, models.HighlightStart, "<mark>")
+	escaped = strings.ReplaceAll(escaped, models.HighlightStop, "</mark>")
+
+	return template.HTML(escaped)
+}
+
 // A string-keyed map which acts as a lookup between the names of our
 // custom template functions and the functions themselves.
-var functions = template.FuncMap{"humanDate": humanDate}
+var functions = template.FuncMap{
+	"humanDate":   humanDate,
+	"markMatches": markMatches,
+	"languages":   func() []highlight.Language { return highlight.Languages },
+	"language":    highlight.Label,
+	"sideBySide":  diff.SideBySide,
+	"viewChart":   viewChart,
+	"totalViews":  totalViews,
+	"plural":      plural,
+}
 
 func newTemplateCache() (map[string]*template.Template, error) {
 	cache := map[string]*template.Template{}
@@ -60,5 +101,13 @@ func newTemplateCache() (map[string]*template.Template, error) {
 		cache[name] = ts
 	}
 
+	// The embed page stands alone, without the site's header and footer.
+	ts, err := template.New("embed.html").Funcs(functions).ParseFS(ui.Files, "html/embed.html")
+	if err != nil {
+		return nil, err
+	}
+
+	cache["embed.html"] = ts
+
 	return cache, nil
 }
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"html/template"
 	"testing"
 	"time"
 
 	"snippetbox.davc.io/internal/assert"
+	"snippetbox.davc.io/internal/models"
 )
 
 func TestHumanDate(t *testing.T) {
@@ -36,3 +38,29 @@ func TestHumanDate(t *testing.T) {
 		})
 	}
 }
+
+func TestMarkMatches(t *testing.T) {
+	headline := "<b>" + models.HighlightStart + "pond" + models.HighlightStop + "</b>"
+
+	assert.Equal(t, markMatches(headline), template.HTML("&lt;b&gt;<mark>pond</mark>&lt;/b&gt;"))
+}
+
+func TestViewChart(t *testing.T) {
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	daily := []*models.DailyViews{
+		{Day: day, Views: 0},
+		{Day: day.AddDate(0, 0, 1), Views: 4, Visitors: 3},
+		{Day: day.AddDate(0, 0, 2), Views: 1, Visitors: 1},
+	}
+
+	chart := string(viewChart(daily))
+
+	assert.StringContains(t, chart, "aria-label='5 views over the last 3 days'")
+	assert.StringContains(t, chart, "<title>02 May 2024: 4 views, 3 visitors</title>")
+	assert.StringContains(t, chart, "<title>03 May 2024: 1 view, 1 visitor</title>")
+	assert.StringContains(t, chart, ">01 May<")
+	assert.StringContains(t, chart, ">03 May<")
+
+	assert.Equal(t, viewChart(nil), template.HTML(""))
+}
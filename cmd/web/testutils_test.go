@@ -5,6 +5,7 @@ import (
 	"html"
 	"io"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"net/http/cookiejar"
 	"net/http/httptest"
@@ -47,14 +48,29 @@ func newTestApplication(t *testing.T) *application {
 	sessionManager.Lifetime = 12 * time.Hour
 	sessionManager.Cookie.Secure = true
 
+	viewRecorder, err := newViewRecorder(&mocks.ViewModel{}, 100, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	return &application{
 		errorLog:       log.New(io.Discard, "", 0),
 		infoLog:        log.New(io.Discard, "", 0),
 		snippets:       &mocks.SnippetModel{},
 		users:          &mocks.UserModel{},
+		tags:           &mocks.TagModel{},
+		stars:          &mocks.StarModel{},
+		comments:       &mocks.CommentModel{},
+		collections:    &mocks.CollectionModel{},
+		views:          &mocks.ViewModel{},
+		viewRecorder:   viewRecorder,
 		templateCache:  templateCache,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
+		unlockLimiter:  newUnlockLimiter(3, time.Minute),
+		pageSize:       10,
+		minExpiry:      5 * time.Minute,
+		frameAncestors: []string{"https://wiki.example.com"},
 	}
 }
 
@@ -110,3 +126,61 @@ func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (i
 
 	return rs.StatusCode, rs.Header, string(body)
 }
+
+// Post form as multipart/form-data, with files mapping upload filenames to
+// their content under the "upload" field.
+func (ts *testServer) postMultipart(t *testing.T, urlPath string, form url.Values, files map[string]string) (int, http.Header, string) {
+	buf := new(bytes.Buffer)
+	mw := multipart.NewWriter(buf)
+
+	for key, values := range form {
+		for _, value := range values {
+			err := mw.WriteField(key, value)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	for filename, content := range files {
+		fw, err := mw.CreateFormFile("upload", filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = io.WriteString(fw, content)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := mw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rs, err := ts.Client().Post(ts.URL+urlPath, mw.FormDataContentType(), buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rs.Body.Close()
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rs.StatusCode, rs.Header, string(body)
+}
+
+// Log in as the mock user alice@example.com.
+func (ts *testServer) login(t *testing.T) {
+	_, _, body := ts.get(t, "/user/login")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("email", "alice@example.com")
+	form.Add("password", "pa$$word")
+	form.Add("csrf_token", validCSRFToken)
+	ts.postForm(t, "/user/login", form)
+}
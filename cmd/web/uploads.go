@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"snippetbox.davc.io/internal/highlight"
+	"snippetbox.davc.io/internal/models/validator"
+)
+
+// Largest file that can be uploaded to make a snippet.
+const maxUploadSize = 512 << 10
+
+// Largest request body accepted by forms that take uploads: a full set of
+// files plus the rest of the form.
+const maxUploadRequest = maxFiles*maxUploadSize + 1<<20
+
+// Like decodePostForm, for forms that may be sent as multipart/form-data
+// with files, which are left in r.MultipartForm. Forms sent without files
+// are decoded too.
+func (self *application) decodeMultipartForm(r *http.Request, dst any) error {
+	err := r.ParseMultipartForm(maxUploadRequest)
+	if err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		return err
+	}
+
+	return self.decodeForm(r, dst)
+}
+
+// Add files uploaded as the upload field of the create form to the form. The
+// first fills in the snippet's first file when its content is blank, and the
+// others follow any files already on the form. The title and languages
+// default from the filenames. Uploads that are too large or aren't UTF-8
+// text are reported under "upload" and left out.
+func readUploads(form *snippetCreateForm, r *http.Request) error {
+	if r.MultipartForm == nil {
+		return nil
+	}
+
+	uploads := r.MultipartForm.File["upload"]
+
+	if len(uploads) > maxFiles {
+		form.AddFieldError("upload", fmt.Sprintf("You cannot upload more than %d files", maxFiles))
+		return nil
+	}
+
+	for _, header := range uploads {
+		content, ok, err := readUpload(&form.Validator, header)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		filename := cleanFilename(header.Filename)
+		language := highlight.ForFilename(filename)
+
+		if form.Title == "" {
+			form.Title = filename
+		}
+
+		if strings.TrimSpace(form.Content) == "" {
+			form.Filename, form.Content, form.Language = filename, content, language
+			continue
+		}
+
+		form.Files = append(form.Files, snippetFileForm{Filename: filename, Content: content, Language: language})
+	}
+
+	return nil
+}
+
+// Read an uploaded file. When it's too large or isn't UTF-8 text, ok is
+// false and an error is recorded.
+func readUpload(v *validator.Validator, header *multipart.FileHeader) (content string, ok bool, err error) {
+	name := cleanFilename(header.Filename)
+
+	if header.Size > maxUploadSize {
+		v.AddFieldError("upload", fmt.Sprintf("%s is larger than %d KB", name, maxUploadSize>>10))
+		return "", false, nil
+	}
+
+	f, err := header.Open()
+	if err != nil {
+		return "", false, err
+	}
+
+	defer f.Close()
+
+	b, err := io.ReadAll(io.LimitReader(f, maxUploadSize))
+	if err != nil {
+		return "", false, err
+	}
+
+	// Text files don't contain NUL bytes, so like git take one as a sign of
+	// binary content.
+	if bytes.IndexByte(b, 0) != -1 {
+		v.AddFieldError("upload", fmt.Sprintf("%s looks like a binary file", name))
+		return "", false, nil
+	}
+
+	if !utf8.Valid(b) {
+		v.AddFieldError("upload", fmt.Sprintf("%s isn't UTF-8 text", name))
+		return "", false, nil
+	}
+
+	return string(b), true, nil
+}
+
+// Turn the name of an uploaded file into one allowed on snippets, replacing
+// unsupported characters with "-".
+func cleanFilename(filename string) string {
+	// Some browsers send the full path of the file.
+	filename = path.Base(strings.ReplaceAll(filename, `\`, "/"))
+
+	filename = strings.Map(func(c rune) rune {
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.ContainsRune("._-", c) {
+			return c
+		}
+		return '-'
+	}, filename)
+
+	if len(filename) > 100 {
+		filename = filename[len(filename)-100:]
+	}
+
+	if filename == "." || filename == ".." || filename == "/" {
+		return "upload.txt"
+	}
+
+	return filename
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"snippetbox.davc.io/internal/models"
+)
+
+// How many views can wait to be saved before new ones are dropped.
+const viewBufferSize = 4096
+
+// Most views saved by a single insert.
+const viewBatchSize = 200
+
+// Longest a view waits to be saved when traffic is light.
+const viewFlushInterval = 5 * time.Second
+
+// Saves views of snippets in the background, so that pages don't wait on the
+// database to count them. When the database falls behind and the buffer
+// fills up, views are dropped rather than holding up requests.
+type viewRecorder struct {
+	model    models.ViewModelInterface
+	views    chan *models.View
+	key      []byte
+	dropped  atomic.Int64
+	errorLog *log.Logger
+}
+
+// The key behind client hashes is made here and never stored, so the hashes
+// can't be traced back to visitors, even by whoever runs the server.
+func newViewRecorder(model models.ViewModelInterface, bufferSize int, errorLog *log.Logger) (*viewRecorder, error) {
+	key := make([]byte, 32)
+
+	_, err := rand.Read(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &viewRecorder{
+		model:    model,
+		views:    make(chan *models.View, bufferSize),
+		key:      key,
+		errorLog: errorLog,
+	}, nil
+}
+
+// Queue a view of the snippet made by the request.
+func (self *viewRecorder) record(r *http.Request, snippetID int) {
+	now := time.Now()
+
+	v := &models.View{
+		SnippetID: snippetID,
+		Viewed:    now,
+		Referrer:  referrerHost(r),
+		Client:    self.clientHash(r, now),
+	}
+
+	select {
+	case self.views <- v:
+	default:
+		self.dropped.Add(1)
+	}
+}
+
+// Save queued views in batches of up to batchSize, and at least every
+// interval, until ctx is cancelled. Views still queued then are saved before
+// returning.
+func (self *viewRecorder) run(ctx context.Context, batchSize int, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	batch := make([]*models.View, 0, batchSize)
+
+	for {
+		select {
+		case v := <-self.views:
+			batch = append(batch, v)
+			if len(batch) < batchSize {
+				continue
+			}
+		case <-ticker.C:
+		case <-ctx.Done():
+			for {
+				select {
+				case v := <-self.views:
+					batch = append(batch, v)
+				default:
+					self.save(batch)
+					return
+				}
+			}
+		}
+
+		self.save(batch)
+		batch = batch[:0]
+	}
+}
+
+// Insert a batch of views, logging the error when that fails and how many
+// views were dropped since the last batch.
+func (self *viewRecorder) save(batch []*models.View) {
+	if dropped := self.dropped.Swap(0); dropped > 0 {
+		self.errorLog.Printf("views: dropped %d views with the buffer full", dropped)
+	}
+
+	if len(batch) == 0 {
+		return
+	}
+
+	err := self.model.Insert(batch)
+	if err != nil {
+		self.errorLog.Printf("views: saving %d views: %v", len(batch), err)
+	}
+}
+
+// A hash of the client's IP address and user agent, which tells visitors
+// apart on a day. The day is part of the hash, so a visitor gets a new one
+// every day and can't be followed from one to the next.
+func (self *viewRecorder) clientHash(r *http.Request, now time.Time) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	mac := hmac.New(sha256.New, self.key)
+	mac.Write([]byte(now.UTC().Format(time.DateOnly) + "\n" + ip + "\n" + r.UserAgent()))
+
+	return hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// The host of the page that linked to the request, without a leading "www.",
+// so views can be credited to sites without keeping the pages they came from.
+// Links within the site and referrers that aren't web pages give "".
+func referrerHost(r *http.Request) string {
+	u, err := url.Parse(r.Referer())
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+
+	own := r.Host
+	if h, _, err := net.SplitHostPort(own); err == nil {
+		own = h
+	}
+
+	if host == strings.TrimPrefix(strings.ToLower(own), "www.") || len(host) > 255 {
+		return ""
+	}
+
+	return host
+}
+
+// Record a view of the snippet, unless it's the owner looking at their own.
+func (self *application) recordView(r *http.Request, snippet *models.Snippet) {
+	if self.isOwner(r, snippet) {
+		return
+	}
+
+	self.viewRecorder.record(r, snippet.ID)
+}
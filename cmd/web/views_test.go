@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"snippetbox.davc.io/internal/assert"
+	"snippetbox.davc.io/internal/models/mocks"
+)
+
+func TestReferrerHost(t *testing.T) {
+	tests := []struct {
+		name    string
+		referer string
+		want    string
+	}{
+		{
+			name:    "Other site",
+			referer: "https://news.ycombinator.com/item?id=1",
+			want:    "news.ycombinator.com",
+		},
+		{
+			name:    "Leading www",
+			referer: "https://WWW.Reddit.com/r/golang/",
+			want:    "reddit.com",
+		},
+		{
+			name:    "Same site",
+			referer: "https://snippetbox.example.com/search?q=pond",
+			want:    "",
+		},
+		{
+			name:    "None",
+			referer: "",
+			want:    "",
+		},
+		{
+			name:    "Not a web page",
+			referer: "android-app://com.example/",
+			want:    "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "https://snippetbox.example.com:4000/snippet/view/Bq3xT7nLwP", nil)
+			r.Header.Set("Referer", tt.referer)
+
+			assert.Equal(t, referrerHost(r), tt.want)
+		})
+	}
+}
+
+func TestViewRecorder(t *testing.T) {
+	model := &mocks.ViewModel{}
+
+	var errorLog bytes.Buffer
+
+	recorder, err := newViewRecorder(model, 2, log.New(&errorLog, "", 0))
+	assert.NilError(t, err)
+
+	r := httptest.NewRequest(http.MethodGet, "/snippet/view/Bq3xT7nLwP", nil)
+	r.Header.Set("Referer", "https://reddit.com/r/golang/")
+
+	// The third view doesn't fit in the buffer.
+	recorder.record(r, 1)
+	recorder.record(r, 1)
+	recorder.record(r, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	recorder.run(ctx, 10, time.Hour)
+
+	views := model.Views()
+	assert.Equal(t, len(views), 2)
+	assert.Equal(t, views[0].SnippetID, 1)
+	assert.Equal(t, views[0].Referrer, "reddit.com")
+	assert.Equal(t, len(views[0].Client), 16)
+	assert.StringContains(t, errorLog.String(), "views: dropped 1 views")
+
+	t.Run("Client hash", func(t *testing.T) {
+		day := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+		other := httptest.NewRequest(http.MethodGet, "/", nil)
+		other.RemoteAddr = "203.0.113.9:1234"
+
+		assert.Equal(t, recorder.clientHash(r, day), recorder.clientHash(r, day.Add(time.Hour)))
+		assert.Equal(t, recorder.clientHash(r, day) == recorder.clientHash(r, day.AddDate(0, 0, 1)), false)
+		assert.Equal(t, recorder.clientHash(r, day) == recorder.clientHash(other, day), false)
+	})
+}
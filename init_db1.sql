@@ -6,17 +6,154 @@ LC_CTYPE = 'en_US.UTF-8' TEMPLATE template0;
 -- Connect to database
 \c snippetbox;
 
+-- Users
+create table users (
+    id serial not null primary key,
+    name varchar(255) not null,
+    email varchar(255) not null,
+    hashed_password char(60) not null,
+    created timestamptz default (now() at time zone 'utc')
+);
+
+alter table users add constraint users_email_key unique (email);
+
+
 -- Snippets
 -- serial is auto-incrementing integer type
 create table snippets (
     id serial not null primary key,
+    slug varchar(10) not null unique, -- random identifier used in URLs
+    user_id integer not null references users(id) on delete cascade,
+    title varchar(100) not null,
+    filename varchar(100) not null default '', -- name of the first file, if given
+    content text not null,
+    language varchar(30) not null default 'plaintext',
+    highlighted text not null default '', -- content rendered by internal/highlight
+    visibility varchar(10) not null default 'public'
+        check (visibility in ('public', 'unlisted', 'private')),
+    hashed_password char(60), -- bcrypt hash of the viewing password, if any
+    views_left integer check (views_left >= 0), -- null when views are unlimited
+    created timestamptz default (now() at time zone 'utc'),
+    expires timestamptz, -- null when the snippet never expires
+    forked_from integer references snippets(id) on delete set null, -- the snippet this one was copied from
+    deleted_at timestamptz, -- set while the snippet is in its owner's trash
+    search tsvector generated always as (
+        setweight(to_tsvector('english', title), 'A') ||
+        setweight(to_tsvector('english', content), 'B')
+    ) stored
+);
+
+create index idx_snippets_created on snippets(created, id);
+create index idx_snippets_user_id on snippets(user_id);
+create index idx_snippets_expires on snippets(expires);
+create index idx_snippets_forked_from on snippets(forked_from);
+create index idx_snippets_search on snippets using gin(search);
+
+-- Every saved version of a snippet, including the first one.
+create table snippet_revisions (
+    id serial not null primary key,
+    snippet_id integer not null references snippets(id) on delete cascade,
+    version integer not null,
+    user_id integer not null references users(id) on delete cascade,
     title varchar(100) not null,
     content text not null,
     created timestamptz default (now() at time zone 'utc'),
-    expires timestamptz not null
+    unique (snippet_id, version)
+);
+
+
+-- Files after the first of multi-file snippets
+create table snippet_files (
+    id serial not null primary key,
+    snippet_id integer not null references snippets(id) on delete cascade,
+    position integer not null, -- from 2, as the snippet holds the first file
+    filename varchar(100) not null,
+    language varchar(30) not null,
+    content text not null,
+    highlighted text not null,
+    unique (snippet_id, position),
+    unique (snippet_id, filename)
+);
+
+
+-- Tags
+create table tags (
+    id serial not null primary key,
+    name varchar(30) not null unique
+);
+
+create table snippet_tags (
+    snippet_id integer not null references snippets(id) on delete cascade,
+    tag_id integer not null references tags(id) on delete cascade,
+    primary key (snippet_id, tag_id)
+);
+
+create index idx_snippet_tags_tag_id on snippet_tags(tag_id);
+
+
+-- Views of snippets, for their owners' stats pages
+create table snippet_views (
+    id bigserial not null primary key,
+    snippet_id integer not null references snippets(id) on delete cascade,
+    viewed timestamptz not null default now(),
+    referrer varchar(255) not null default '', -- host of the linking page
+    client char(16) not null -- changes daily, so visitors can't be followed
+);
+
+create index idx_snippet_views_snippet_id on snippet_views(snippet_id, viewed);
+
+
+-- Snippets users have starred to find again
+create table stars (
+    user_id integer not null references users(id) on delete cascade,
+    snippet_id integer not null references snippets(id) on delete cascade,
+    created timestamptz not null default now(),
+    primary key (user_id, snippet_id)
+);
+
+create index idx_stars_snippet_id on stars(snippet_id);
+
+
+-- Comments on snippets, with one level of replies
+create table comments (
+    id serial not null primary key,
+    snippet_id integer not null references snippets(id) on delete cascade,
+    user_id integer not null references users(id) on delete cascade,
+    parent_id integer references comments(id) on delete cascade, -- set on replies
+    line varchar(20) not null default '', -- anchor of a line, such as L12
+    body text not null,
+    rendered text not null,
+    created timestamptz not null default now(),
+    updated timestamptz,
+    deleted_at timestamptz -- kept as a placeholder while it has replies
+);
+
+create index idx_comments_snippet_id on comments(snippet_id, created);
+create index idx_comments_parent_id on comments(parent_id);
+
+
+-- Collections of snippets, in the order their owners choose
+create table collections (
+    id serial not null primary key,
+    slug varchar(10) not null unique,
+    user_id integer not null references users(id) on delete cascade,
+    title varchar(100) not null,
+    description text not null default '',
+    visibility varchar(10) not null default 'public'
+        check (visibility in ('public', 'unlisted', 'private')),
+    created timestamptz not null default now()
+);
+
+create index idx_collections_user_id on collections(user_id);
+
+create table collection_snippets (
+    collection_id integer not null references collections(id) on delete cascade,
+    snippet_id integer not null references snippets(id) on delete cascade,
+    position integer not null, -- order within the collection, from 1
+    primary key (collection_id, snippet_id)
 );
 
-create index idx_snippets_created on snippets(created);
+create index idx_collection_snippets_snippet_id on collection_snippets(snippet_id);
 
 
 -- Sessions
@@ -29,37 +166,39 @@ create table sessions (
 create index sessions_expiry_idx on sessions (expiry);
 
 
--- Users
-create table users (
-    id serial not null primary key,
-    name varchar(255) not null,
-    email varchar(255) not null,
-    hashed_password char(60) not null,
-    created timestamptz default (now() at time zone 'utc')
+-- Dummy records 
+INSERT INTO users (name, email, hashed_password) VALUES (
+    'Alice Jones',
+    'alice@example.com',
+    '$2a$12$NuTjWXm3KKntReFwyBVHyuf/to.HEwTy.eS206TNfkGfr6HzGJSWG'
 );
 
-alter table users add constraint users_email_key unique (email);
-
-
--- Dummy records 
-INSERT INTO snippets (title, content, expires) VALUES (
+INSERT INTO snippets (slug, user_id, title, content, expires) VALUES (
+    'Bq3xT7nLwP',
+    1,
     'An old silent pond',
     'An old silent pond...\nA frog jumps into the pond,\nsplash! Silence again.\n\n– Matsuo Bashō',
     now() + interval '365 days'
 );
 
-INSERT INTO snippets (title, content, expires) VALUES (
+INSERT INTO snippets (slug, user_id, title, content, expires) VALUES (
+    'Kd8mRz2VhY',
+    1,
     'Over the wintry forest',
     'Over the wintry\nforest, winds howl in rage\nwith no leaves to blow.\n\n– Natsume Soseki',
     now() + interval '365 days'
 );
 
-INSERT INTO snippets (title, content, expires) VALUES (
+INSERT INTO snippets (slug, user_id, title, content, expires) VALUES (
+    'Wf5cNs9GjA',
+    1,
     'First autumn morning',
     'First autumn morning\nthe mirror I stare into\nshows my father''s face.\n\n– Murakami Kijo',
     now() + interval '7 days'
 );
 
+INSERT INTO snippet_revisions (snippet_id, version, user_id, title, content, created)
+SELECT id, 1, user_id, title, content, created FROM snippets;
 
 
 -- For unit tests and end-to-end tests.
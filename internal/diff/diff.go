@@ -0,0 +1,404 @@
+// Package diff computes line-based differences between two texts using
+// Myers' O(ND) algorithm and formats them as unified or side-by-side views.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+// String returns the lowercase name of the operation, which templates use
+// as a CSS class.
+func (o Op) String() string {
+	switch o {
+	case Insert:
+		return "insert"
+	case Delete:
+		return "delete"
+	default:
+		return "equal"
+	}
+}
+
+// A single line of an edit script. OldNumber and NewNumber are 1-based line
+// numbers in the old and new text, or 0 when the line is absent from that side.
+type Line struct {
+	Op        Op
+	Text      string
+	OldNumber int
+	NewNumber int
+	// False when this is the last line of its text and it isn't terminated
+	// by a newline.
+	Newline bool
+}
+
+// A group of changes surrounded by unchanged context lines.
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []Line
+}
+
+// Header returns the "@@ -l,s +l,s @@" line of a unified diff hunk.
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%s +%s @@", formatRange(h.OldStart, h.OldLines), formatRange(h.NewStart, h.NewLines))
+}
+
+// A row of a side-by-side view. Either side is nil when the row only has a
+// line on the other side.
+type Row struct {
+	Old *Line
+	New *Line
+}
+
+// Lines returns the edit script that turns a into b, one entry per line.
+func Lines(a, b string) []Line {
+	oldLines := splitLines(a)
+	newLines := splitLines(b)
+
+	// Strip the common prefix and suffix first; this keeps the search space
+	// (and the memory used by the trace) small for typical edits.
+	prefix := 0
+	for prefix < len(oldLines) && prefix < len(newLines) && oldLines[prefix] == newLines[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(oldLines)-prefix && suffix < len(newLines)-prefix &&
+		oldLines[len(oldLines)-1-suffix] == newLines[len(newLines)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]Op, 0, len(oldLines)+len(newLines))
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, Equal)
+	}
+	ops = append(ops, myers(oldLines[prefix:len(oldLines)-suffix], newLines[prefix:len(newLines)-suffix])...)
+	for i := 0; i < suffix; i++ {
+		ops = append(ops, Equal)
+	}
+
+	lines := make([]Line, 0, len(ops))
+	x, y := 0, 0
+
+	for _, op := range ops {
+		var l Line
+
+		switch op {
+		case Equal:
+			l = newLine(Equal, newLines[y])
+			x++
+			y++
+			l.OldNumber, l.NewNumber = x, y
+		case Delete:
+			l = newLine(Delete, oldLines[x])
+			x++
+			l.OldNumber = x
+		case Insert:
+			l = newLine(Insert, newLines[y])
+			y++
+			l.NewNumber = y
+		}
+
+		lines = append(lines, l)
+	}
+
+	return lines
+}
+
+// Hunks groups an edit script into hunks with up to context unchanged lines
+// around each change. Changes separated by at most 2*context unchanged lines
+// share a hunk.
+func Hunks(lines []Line, context int) []Hunk {
+	hunks := []Hunk{}
+
+	i := 0
+	for i < len(lines) {
+		// Find the next change.
+		for i < len(lines) && lines[i].Op == Equal {
+			i++
+		}
+		if i == len(lines) {
+			break
+		}
+
+		start := max(i-context, 0)
+
+		// Extend the hunk while the gap to the next change is small enough.
+		end := i
+		for end < len(lines) {
+			if lines[end].Op != Equal {
+				end++
+				continue
+			}
+
+			gap := end
+			for gap < len(lines) && lines[gap].Op == Equal {
+				gap++
+			}
+			if gap == len(lines) || gap-end > 2*context {
+				break
+			}
+			end = gap
+		}
+
+		stop := min(end+context, len(lines))
+		hunks = append(hunks, newHunk(lines, start, stop))
+		i = stop
+	}
+
+	return hunks
+}
+
+// SideBySide pairs the lines of a hunk into rows. Runs of deletions followed
+// by insertions are shown next to each other.
+func SideBySide(h Hunk) []Row {
+	rows := []Row{}
+
+	lines := h.Lines
+	for i := 0; i < len(lines); {
+		if lines[i].Op == Equal {
+			rows = append(rows, Row{Old: &lines[i], New: &lines[i]})
+			i++
+			continue
+		}
+
+		var deleted, inserted []*Line
+		for i < len(lines) && lines[i].Op == Delete {
+			deleted = append(deleted, &lines[i])
+			i++
+		}
+		for i < len(lines) && lines[i].Op == Insert {
+			inserted = append(inserted, &lines[i])
+			i++
+		}
+
+		for j := 0; j < max(len(deleted), len(inserted)); j++ {
+			var row Row
+			if j < len(deleted) {
+				row.Old = deleted[j]
+			}
+			if j < len(inserted) {
+				row.New = inserted[j]
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	return rows
+}
+
+// Unified returns a patch in unified diff format that turns a into b. The
+// result is empty when both texts are equal.
+func Unified(oldName, newName, a, b string, context int) string {
+	hunks := Hunks(Lines(a, b), context)
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+
+	for _, h := range hunks {
+		sb.WriteString(h.Header())
+		sb.WriteByte('\n')
+
+		for _, l := range h.Lines {
+			switch l.Op {
+			case Equal:
+				sb.WriteByte(' ')
+			case Insert:
+				sb.WriteByte('+')
+			case Delete:
+				sb.WriteByte('-')
+			}
+
+			sb.WriteString(l.Text)
+			sb.WriteByte('\n')
+
+			if !l.Newline {
+				sb.WriteString("\\ No newline at end of file\n")
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+// Split text into lines, keeping the trailing newline of each line so that
+// a missing newline at the end of the text counts as a change.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+func newLine(op Op, raw string) Line {
+	text, found := strings.CutSuffix(raw, "\n")
+	return Line{Op: op, Text: text, Newline: found}
+}
+
+func newHunk(lines []Line, start, stop int) Hunk {
+	h := Hunk{Lines: lines[start:stop]}
+
+	// Count how many lines of each text precede the hunk.
+	for _, l := range lines[:start] {
+		if l.Op != Insert {
+			h.OldStart++
+		}
+		if l.Op != Delete {
+			h.NewStart++
+		}
+	}
+
+	for _, l := range h.Lines {
+		if l.Op != Insert {
+			h.OldLines++
+		}
+		if l.Op != Delete {
+			h.NewLines++
+		}
+	}
+
+	return h
+}
+
+// Format a hunk range the way diff(1) does: start is the number of lines
+// preceding the range, and single-line ranges omit the count.
+func formatRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	default:
+		return fmt.Sprintf("%d,%d", start+1, count)
+	}
+}
+
+// Largest edit distance myers searches. The trace grows with its square, so
+// past this texts are treated as entirely different rather than letting a
+// single diff of two large, unrelated texts take gigabytes of memory.
+const maxEditDistance = 1000
+
+// Find the shortest edit script between a and b. For every edit distance d
+// the trace keeps the furthest reaching x on each diagonal k = x - y, which
+// is enough to walk back from (len(a), len(b)) to the origin. When the
+// shortest script is longer than maxEditDistance, the one returned deletes
+// all of a and inserts all of b.
+func myers(a, b []string) []Op {
+	n, m := len(a), len(b)
+	if n == 0 && m == 0 {
+		return nil
+	}
+
+	offset := n + m
+	v := make([]int, 2*offset+2)
+	trace := [][]int{}
+
+search:
+	for d := 0; d <= n+m; d++ {
+		if d > maxEditDistance {
+			return replaceAll(n, m)
+		}
+
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+2]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	ops := make([]Op, 0, n+m)
+	x, y := n, m
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		// trace[d] holds v for diagonals -d..d+1 before step d ran, which
+		// is the state reached after d-1 edits.
+		prev := trace[d]
+		at := func(k int) int { return prev[k+d] }
+
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			ops = append(ops, Equal)
+			x--
+			y--
+		}
+
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, Insert)
+			} else {
+				ops = append(ops, Delete)
+			}
+		}
+
+		x, y = prevX, prevY
+	}
+
+	// The walk above went from the end to the start.
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+
+	return ops
+}
+
+// The edit script that deletes all n lines of one text and inserts all m of
+// another.
+func replaceAll(n, m int) []Op {
+	ops := make([]Op, 0, n+m)
+	for i := 0; i < n; i++ {
+		ops = append(ops, Delete)
+	}
+	for i := 0; i < m; i++ {
+		ops = append(ops, Insert)
+	}
+	return ops
+}
@@ -0,0 +1,163 @@
+package diff
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"snippetbox.davc.io/internal/assert"
+)
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want string
+	}{
+		{
+			name: "Equal",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+			want: "",
+		},
+		{
+			name: "Change",
+			a:    "a\nb\nc\n",
+			b:    "a\nB\nc\n",
+			want: "--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			name: "Insert into empty",
+			a:    "",
+			b:    "a\n",
+			want: "--- old\n+++ new\n@@ -0,0 +1 @@\n+a\n",
+		},
+		{
+			name: "Delete everything",
+			a:    "a\nb\n",
+			b:    "",
+			want: "--- old\n+++ new\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			name: "Missing newline",
+			a:    "a\nb",
+			b:    "a\nb\n",
+			want: "--- old\n+++ new\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+		{
+			name: "Separate hunks",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			b:    "0\n2\n3\n4\n5\n6\n7\n8\n9\nX\n",
+			want: "--- old\n+++ new\n@@ -1,2 +1,2 @@\n-1\n+0\n 2\n@@ -9,2 +9,2 @@\n 9\n-10\n+X\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, Unified("old", "new", tt.a, tt.b, 1), tt.want)
+		})
+	}
+}
+
+func TestSideBySide(t *testing.T) {
+	hunks := Hunks(Lines("a\nb\nc\n", "a\nB\nC\nc\n"), 3)
+	assert.Equal(t, len(hunks), 1)
+
+	rows := SideBySide(hunks[0])
+	assert.Equal(t, len(rows), 4)
+
+	assert.Equal(t, rows[1].Old.Text, "b")
+	assert.Equal(t, rows[1].New.Text, "B")
+	assert.Equal(t, rows[2].Old == nil, true)
+	assert.Equal(t, rows[2].New.Text, "C")
+	assert.Equal(t, rows[3].Old.OldNumber, 3)
+	assert.Equal(t, rows[3].New.NewNumber, 4)
+}
+
+// The edit script must rebuild both texts and be as short as the one implied
+// by their longest common subsequence.
+func TestLinesMinimal(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 500; i++ {
+		a := randomText(r)
+		b := randomText(r)
+
+		lines := Lines(a, b)
+
+		var oldText, newText []string
+		edits := 0
+		for _, l := range lines {
+			if l.Op != Insert {
+				oldText = append(oldText, l.Text)
+			}
+			if l.Op != Delete {
+				newText = append(newText, l.Text)
+			}
+			if l.Op != Equal {
+				edits++
+			}
+		}
+
+		assert.Equal(t, strings.Join(oldText, "\n"), strings.TrimSuffix(a, "\n"))
+		assert.Equal(t, strings.Join(newText, "\n"), strings.TrimSuffix(b, "\n"))
+
+		n, m := len(splitLines(a)), len(splitLines(b))
+		assert.Equal(t, edits, n+m-2*lcs(splitLines(a), splitLines(b)))
+	}
+}
+
+func TestLinesTooDifferent(t *testing.T) {
+	// Changing every other line takes more edits than are searched.
+	var a, b strings.Builder
+	for i := 0; i < maxEditDistance; i++ {
+		fmt.Fprintf(&a, "same %d\nold %d\n", i, i)
+		fmt.Fprintf(&b, "same %d\nnew %d\n", i, i)
+	}
+	a.WriteString("end\n")
+	b.WriteString("end\n")
+
+	lines := Lines("start\n"+a.String(), "begin\n"+b.String())
+
+	ops := map[Op]int{}
+	for _, l := range lines {
+		ops[l.Op]++
+	}
+
+	// Everything but the common last line is replaced.
+	assert.Equal(t, ops[Equal], 1)
+	assert.Equal(t, ops[Delete], 2*maxEditDistance+1)
+	assert.Equal(t, ops[Insert], 2*maxEditDistance+1)
+	assert.Equal(t, lines[0].Op, Delete)
+	assert.Equal(t, lines[len(lines)-2].Op, Insert)
+}
+
+func randomText(r *rand.Rand) string {
+	var sb strings.Builder
+	for i := r.Intn(12); i > 0; i-- {
+		sb.WriteByte(byte('a' + r.Intn(3)))
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+func lcs(a, b []string) int {
+	dp := make([][]int, len(a)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else {
+				dp[i][j] = max(dp[i+1][j], dp[i][j+1])
+			}
+		}
+	}
+
+	return dp[0][0]
+}
@@ -0,0 +1,298 @@
+// Package highlight renders snippet content as syntax highlighted HTML.
+//
+// The output only uses CSS classes (see ui/static/css/chroma.css), never
+// inline styles, so it works under a Content-Security-Policy that forbids
+// them.
+package highlight
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// Asks Highlight to guess the language from the content.
+const Auto = "auto"
+
+// Used when a language can't be guessed.
+const PlainText = "plaintext"
+
+// Markdown snippets are rendered as documents by package markdown rather than
+// highlighted as source.
+const Markdown = "markdown"
+
+type Language struct {
+	Name  string
+	Label string
+	// File extension, including the dot, used when a snippet is downloaded.
+	Extension string
+}
+
+// Languages offered on the snippet forms, in display order.
+var Languages = []Language{
+	{"bash", "Bash", ".sh"},
+	{"c", "C", ".c"},
+	{"cpp", "C++", ".cpp"},
+	{"csharp", "C#", ".cs"},
+	{"css", "CSS", ".css"},
+	{"diff", "Diff", ".diff"},
+	{"docker", "Dockerfile", ".dockerfile"},
+	{"go", "Go", ".go"},
+	{"html", "HTML", ".html"},
+	{"java", "Java", ".java"},
+	{"javascript", "JavaScript", ".js"},
+	{"json", "JSON", ".json"},
+	{"kotlin", "Kotlin", ".kt"},
+	{"lua", "Lua", ".lua"},
+	{"makefile", "Makefile", ".mk"},
+	{Markdown, "Markdown", ".md"},
+	{"php", "PHP", ".php"},
+	{PlainText, "Plain text", ".txt"},
+	{"python", "Python", ".py"},
+	{"ruby", "Ruby", ".rb"},
+	{"rust", "Rust", ".rs"},
+	{"sql", "SQL", ".sql"},
+	{"swift", "Swift", ".swift"},
+	{"toml", "TOML", ".toml"},
+	{"typescript", "TypeScript", ".ts"},
+	{"yaml", "YAML", ".yaml"},
+}
+
+// Names returns the names of all languages a form may submit, including Auto.
+func Names() []string {
+	names := []string{Auto}
+	for _, l := range Languages {
+		names = append(names, l.Name)
+	}
+	return names
+}
+
+// File extensions used by the listed languages besides their own.
+var otherExtensions = map[string]string{
+	".bash":     "bash",
+	".cc":       "cpp",
+	".h":        "c",
+	".hpp":      "cpp",
+	".htm":      "html",
+	".kts":      "kotlin",
+	".markdown": Markdown,
+	".mjs":      "javascript",
+	".patch":    "diff",
+	".text":     PlainText,
+	".yml":      "yaml",
+}
+
+// ForFilename returns the name of the language a file is written in judging by
+// its name, or Auto when the name gives no clue.
+func ForFilename(filename string) string {
+	switch strings.ToLower(filename) {
+	case "dockerfile":
+		return "docker"
+	case "makefile", "gnumakefile":
+		return "makefile"
+	}
+
+	ext := strings.ToLower(path.Ext(filename))
+	if ext == "" {
+		return Auto
+	}
+
+	for _, l := range Languages {
+		if l.Extension == ext {
+			return l.Name
+		}
+	}
+
+	if name, ok := otherExtensions[ext]; ok {
+		return name
+	}
+
+	return Auto
+}
+
+// Extension returns the file extension of a language, or ".txt" for one
+// that isn't listed.
+func Extension(name string) string {
+	for _, l := range Languages {
+		if l.Name == name {
+			return l.Extension
+		}
+	}
+	return ".txt"
+}
+
+// Label returns the display name of a language.
+func Label(name string) string {
+	for _, l := range Languages {
+		if l.Name == name {
+			return l.Label
+		}
+	}
+	return name
+}
+
+// Languages chroma may guess which aren't listed, and the listed language
+// each is closest to.
+var otherLexers = map[string]string{
+	"MySQL": "sql",
+}
+
+// Interpreters named on the "#!" line of a script, and their languages.
+var interpreters = map[string]string{
+	"bash":    "bash",
+	"deno":    "javascript",
+	"ksh":     "bash",
+	"lua":     "lua",
+	"node":    "javascript",
+	"nodejs":  "javascript",
+	"php":     "php",
+	"python":  "python",
+	"ruby":    "ruby",
+	"sh":      "bash",
+	"ts-node": "typescript",
+	"zsh":     "bash",
+}
+
+// A Markdown document opens with a heading and goes on to use lists, fenced
+// code or links, which together rule out most comments in source code.
+var (
+	markdownHeadingRX = regexp.MustCompile(`^#{1,6} \S`)
+	markdownBodyRX    = regexp.MustCompile("(?m)^(```|[-*] \\S|\\d+\\. \\S)|\\[[^\\]\n]+\\]\\([^)\\s]+\\)")
+)
+
+// Guess returns the name of the listed language content seems to be written
+// in, or PlainText when there's no telling.
+func Guess(content string) string {
+	if name, ok := scriptLanguage(content); ok {
+		return name
+	}
+
+	if lexer := lexers.Analyse(content); lexer != nil {
+		if name, ok := listedLanguage(lexer); ok {
+			return name
+		}
+	}
+
+	if markdownHeadingRX.MatchString(strings.TrimSpace(content)) && markdownBodyRX.MatchString(content) {
+		return Markdown
+	}
+
+	return PlainText
+}
+
+// The listed language whose lexer is the same as the one given.
+func listedLanguage(lexer chroma.Lexer) (string, bool) {
+	name := lexer.Config().Name
+
+	for _, l := range Languages {
+		if listed := lexers.Get(l.Name); listed != nil && listed.Config().Name == name {
+			return l.Name, true
+		}
+	}
+
+	other, ok := otherLexers[name]
+	return other, ok
+}
+
+// The language of a script run by the interpreter on its "#!" line, such as
+// "#!/usr/bin/env node" or "#!/usr/bin/python3".
+func scriptLanguage(content string) (string, bool) {
+	line, _, _ := strings.Cut(content, "\n")
+
+	rest, ok := strings.CutPrefix(line, "#!")
+	if !ok {
+		return "", false
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return "", false
+	}
+
+	program := path.Base(fields[0])
+	if program == "env" {
+		program = ""
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") && !strings.Contains(f, "=") {
+				program = f
+				break
+			}
+		}
+	}
+
+	name, ok := interpreters[strings.TrimRight(program, "0123456789.")]
+	return name, ok
+}
+
+// Anchors of the lines of a snippet's first file are the line number
+// prefixed with this, such as "L12".
+const LinePrefix = "L"
+
+// Code blocks inside a larger document have no line numbers, whose anchors
+// would clash between blocks.
+var blockFormatter = html.New(html.WithClasses(true))
+
+// Highlight content written in language, or in a guessed language when
+// language is Auto. It returns the HTML together with the name of the
+// language that was used.
+func Highlight(language string, content string) (string, string, error) {
+	return HighlightLines(language, content, LinePrefix)
+}
+
+// HighlightLines is like Highlight, with line anchors made of prefix and the
+// line number, so that several files can be shown on one page.
+func HighlightLines(language string, content string, prefix string) (string, string, error) {
+	if language == Auto {
+		language = Guess(content)
+	}
+
+	lexer := lexers.Get(language)
+	if lexer == nil {
+		lexer = lexers.Get(PlainText)
+		language = PlainText
+	}
+
+	formatter := html.New(
+		html.WithClasses(true),
+		html.WithLineNumbers(true),
+		html.WithLinkableLineNumbers(true, prefix),
+	)
+
+	html, err := format(formatter, lexer, content)
+	if err != nil {
+		return "", "", err
+	}
+
+	return html, language, nil
+}
+
+// Block highlights a code block embedded in another document, such as a
+// fenced block in Markdown. Unknown languages are shown as plain text.
+func Block(language string, content string) (string, error) {
+	lexer := lexers.Get(language)
+	if lexer == nil {
+		lexer = lexers.Get(PlainText)
+	}
+
+	return format(blockFormatter, lexer, content)
+}
+
+func format(f *html.Formatter, lexer chroma.Lexer, content string) (string, error) {
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, content)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	err = f.Format(&sb, styles.Get("github"), iterator)
+	if err != nil {
+		return "", err
+	}
+
+	return sb.String(), nil
+}
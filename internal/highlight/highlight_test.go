@@ -0,0 +1,141 @@
+package highlight
+
+import (
+	"strings"
+	"testing"
+
+	"snippetbox.davc.io/internal/assert"
+
+	"github.com/alecthomas/chroma/v2/lexers"
+)
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name         string
+		language     string
+		content      string
+		wantLanguage string
+		wantHTML     string
+	}{
+		{
+			name:         "Go",
+			language:     "go",
+			content:      "package main\n",
+			wantLanguage: "go",
+			wantHTML:     `<span class="kn">package</span>`,
+		},
+		{
+			name:         "Auto",
+			language:     Auto,
+			content:      "#!/bin/bash\necho hi\n",
+			wantLanguage: "bash",
+			wantHTML:     `<span class="nb">echo</span>`,
+		},
+		{
+			name:         "Unknown",
+			language:     "klingon",
+			content:      "<b>Qapla'</b>",
+			wantLanguage: PlainText,
+			wantHTML:     "&lt;b&gt;Qapla&#39;&lt;/b&gt;",
+		},
+		{
+			name:         "Line anchors",
+			language:     PlainText,
+			content:      "a\nb\n",
+			wantLanguage: PlainText,
+			wantHTML:     `<span class="ln" id="L2"><a class="lnlinks" href="#L2">2</a></span>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html, language, err := Highlight(tt.language, tt.content)
+
+			assert.NilError(t, err)
+			assert.Equal(t, language, tt.wantLanguage)
+			assert.StringContains(t, html, tt.wantHTML)
+			assert.Equal(t, strings.Contains(html, "style="), false)
+		})
+	}
+}
+
+func TestGuess(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "JavaScript",
+			content: "#!/usr/bin/env node\nconsole.log('pond');\n",
+			want:    "javascript",
+		},
+		{
+			name:    "Ruby",
+			content: "#!/usr/bin/ruby2.7 -w\nputs 'pond'\n",
+			want:    "ruby",
+		},
+		{
+			name:    "Markdown",
+			content: "# Pond\n\nSee [the poem](https://example.com/pond).\n",
+			want:    Markdown,
+		},
+		{
+			name:    "Shell comment",
+			content: "# Pond\necho pond\n",
+			want:    PlainText,
+		},
+		{
+			name:    "Go",
+			content: "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"pond\")\n}\n",
+			want:    "go",
+		},
+		{
+			name:    "Unlisted guess",
+			content: "extends Node\n\nfunc _ready():\n\tpass\n",
+			want:    PlainText,
+		},
+		{
+			name:    "Unknown",
+			content: "An old silent pond",
+			want:    PlainText,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := Guess(tt.content)
+
+			assert.Equal(t, name, tt.want)
+		})
+	}
+}
+
+func TestLanguages(t *testing.T) {
+	for _, l := range Languages {
+		if lexers.Get(l.Name) == nil {
+			t.Errorf("no lexer for %q", l.Name)
+		}
+	}
+}
+
+func TestForFilename(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		{"main.go", "go"},
+		{"Query.SQL", "sql"},
+		{"config.yml", "yaml"},
+		{"README.markdown", Markdown},
+		{"Dockerfile", "docker"},
+		{"notes", Auto},
+		{"archive.tar", Auto},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			assert.Equal(t, ForFilename(tt.filename), tt.want)
+		})
+	}
+}
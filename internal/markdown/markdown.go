@@ -0,0 +1,141 @@
+// Package markdown renders GitHub flavoured Markdown to HTML that is safe to
+// embed in a page, along with a lighter variant for short texts such as
+// comments.
+//
+// Raw HTML in the source is dropped by the renderer, and everything it
+// produces then goes through an allow-list sanitizer, so a bug in either one
+// alone can't let scripts through.
+package markdown
+
+import (
+	"bytes"
+	"regexp"
+
+	"snippetbox.davc.io/internal/highlight"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/util"
+)
+
+var md = goldmark.New(
+	goldmark.WithExtensions(
+		extension.NewTable(extension.WithTableCellAlignMethod(extension.TableCellAlignAttribute)),
+		extension.Strikethrough,
+		extension.Linkify,
+		extension.TaskList,
+	),
+	goldmark.WithRendererOptions(
+		renderer.WithNodeRenderers(util.Prioritized(&codeBlockRenderer{}, 100)),
+	),
+)
+
+// Line breaks are kept, as people writing a comment expect them to be.
+var liteMD = goldmark.New(
+	goldmark.WithExtensions(
+		extension.Strikethrough,
+		extension.Linkify,
+	),
+	goldmark.WithRendererOptions(
+		html.WithHardWraps(),
+	),
+)
+
+var policy = newPolicy()
+
+var litePolicy = newLitePolicy()
+
+// Class names produced by the highlighter, e.g. "chroma" or "line".
+var classRX = regexp.MustCompile(`^[a-z0-9]+( [a-z0-9]+)*$`)
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+
+	p.AllowAttrs("class").Matching(classRX).OnElements("pre", "code", "span")
+
+	// Task list items.
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").Matching(regexp.MustCompile(`^$`)).OnElements("input")
+
+	return p
+}
+
+// Emphasis, links, code, lists and quotes. Headings, images and tables are
+// reduced to their text.
+func newLitePolicy() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+
+	p.AllowElements("p", "br", "em", "strong", "del", "code", "pre", "ul", "ol", "li", "blockquote")
+
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowStandardURLs()
+	p.RequireNoFollowOnLinks(true)
+
+	return p
+}
+
+// Render Markdown source to sanitized HTML.
+func Render(source string) (string, error) {
+	var buf bytes.Buffer
+
+	err := md.Convert([]byte(source), &buf)
+	if err != nil {
+		return "", err
+	}
+
+	return Sanitize(buf.String()), nil
+}
+
+// RenderLite renders Markdown source to sanitized HTML with only the inline
+// formatting, lists, quotes and code blocks that suit a short text. Code
+// isn't highlighted.
+func RenderLite(source string) (string, error) {
+	var buf bytes.Buffer
+
+	err := liteMD.Convert([]byte(source), &buf)
+	if err != nil {
+		return "", err
+	}
+
+	return litePolicy.Sanitize(buf.String()), nil
+}
+
+// Sanitize removes every element and attribute that isn't on the allow-list,
+// along with URLs using schemes other than http, https and mailto.
+func Sanitize(html string) string {
+	return policy.Sanitize(html)
+}
+
+// Renders fenced code blocks through the syntax highlighter.
+type codeBlockRenderer struct{}
+
+func (r *codeBlockRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, r.renderFencedCodeBlock)
+}
+
+func (r *codeBlockRenderer) renderFencedCodeBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	n := node.(*ast.FencedCodeBlock)
+
+	var code bytes.Buffer
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		code.Write(line.Value(source))
+	}
+
+	html, err := highlight.Block(string(n.Language(source)), code.String())
+	if err != nil {
+		return ast.WalkStop, err
+	}
+
+	_, err = w.WriteString(html)
+	return ast.WalkContinue, err
+}
@@ -0,0 +1,164 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+
+	"snippetbox.davc.io/internal/assert"
+)
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "Script element",
+			html: `<p>a</p><script>alert(1)</script>`,
+			want: `<p>a</p>`,
+		},
+		{
+			name: "Script in attribute context",
+			html: `<p title="x"><script src="https://evil.example/x.js"></script></p>`,
+			want: `<p title="x"></p>`,
+		},
+		{
+			name: "JavaScript URL",
+			html: `<a href="javascript:alert(1)">click</a>`,
+			want: `click`,
+		},
+		{
+			name: "Mixed case JavaScript URL",
+			html: `<a href=" JaVaScRiPt:alert(1)">click</a>`,
+			want: `click`,
+		},
+		{
+			name: "Event handlers",
+			html: `<img src="a.png" onerror="alert(1)"><p onclick="alert(1)">a</p>`,
+			want: `<img src="a.png"><p>a</p>`,
+		},
+		{
+			name: "Inline style",
+			html: `<span class="k" style="color: red">go</span>`,
+			want: `<span class="k">go</span>`,
+		},
+		{
+			name: "Injected class",
+			html: `<span class="k&quot; onclick=&quot;x">go</span>`,
+			want: `<span>go</span>`,
+		},
+		{
+			name: "Iframe",
+			html: `<iframe src="https://evil.example"></iframe>`,
+			want: ``,
+		},
+		{
+			name: "Safe link",
+			html: `<a href="https://example.com">a</a>`,
+			want: `<a href="https://example.com" rel="nofollow">a</a>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, Sanitize(tt.html), tt.want)
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "Fenced code",
+			source: "```go\npackage main\n```\n",
+			want:   `<pre class="chroma"><code><span class="line"><span class="cl"><span class="kn">package</span> <span class="nx">main</span>`,
+		},
+		{
+			name:   "Table",
+			source: "| a | b |\n|---|--:|\n| 1 | 2 |\n",
+			want:   `<td align="right">2</td>`,
+		},
+		{
+			name:   "Task list",
+			source: "- [x] done\n",
+			want:   `<li><input checked="" disabled="" type="checkbox"> done</li>`,
+		},
+		{
+			name:   "Raw HTML",
+			source: "<script>alert(1)</script>\n\n<b onmouseover=\"alert(1)\">b</b>\n",
+			want:   "<p>b</p>",
+		},
+		{
+			name:   "JavaScript link",
+			source: "[click](javascript:alert(1))\n",
+			want:   "<p>click</p>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html, err := Render(tt.source)
+
+			assert.NilError(t, err)
+			assert.StringContains(t, html, tt.want)
+		})
+	}
+}
+
+func TestRenderLite(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "Emphasis",
+			source: "**bold** and _italic_ and `code`",
+			want:   "<p><strong>bold</strong> and <em>italic</em> and <code>code</code></p>",
+		},
+		{
+			name:   "Line breaks",
+			source: "one\ntwo",
+			want:   "<p>one<br>\ntwo</p>",
+		},
+		{
+			name:   "Link",
+			source: "see https://example.com",
+			want:   `<p>see <a href="https://example.com" rel="nofollow">https://example.com</a></p>`,
+		},
+		{
+			name:   "Heading",
+			source: "# Title",
+			want:   "Title",
+		},
+		{
+			name:   "Image",
+			source: "![alt](https://example.com/a.png)",
+			want:   "<p></p>",
+		},
+		{
+			name:   "Plain code block",
+			source: "```go\npackage main\n```",
+			want:   "<pre><code>package main\n</code></pre>",
+		},
+		{
+			name:   "JavaScript link",
+			source: "[click](javascript:alert(1))",
+			want:   "<p>click</p>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html, err := RenderLite(tt.source)
+
+			assert.NilError(t, err)
+			assert.Equal(t, strings.TrimSpace(html), tt.want)
+		})
+	}
+}
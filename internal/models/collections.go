@@ -0,0 +1,276 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type CollectionModelInterface interface {
+	Insert(userID int, title string, description string, visibility Visibility) (string, error)
+	Get(slug string, viewerID int) (*Collection, error)
+	ByOwner(userID int) ([]*Collection, error)
+	Update(id int, userID int, title string, description string, visibility Visibility) error
+	Delete(id int, userID int) error
+	AddSnippet(id int, userID int, snippetID int) error
+	RemoveSnippet(id int, userID int, snippetID int) error
+	MoveSnippet(id int, userID int, snippetID int, offset int) error
+}
+
+// A named, ordered list of snippets kept by a user. Collections are public,
+// unlisted or private just like snippets.
+type Collection struct {
+	ID          int
+	Slug        string
+	UserID      int
+	Author      string
+	Title       string
+	Description string
+	Visibility  Visibility
+	Created     time.Time
+	// How many snippets the collection holds, including any the viewer
+	// can't see.
+	Size int
+	// The snippets the viewer may see, in order. Only filled in by Get.
+	Snippets []*Snippet
+}
+
+type CollectionModel struct {
+	DB *pgxpool.Pool
+}
+
+const collectionSelect = `SELECT c.id, c.slug, c.user_id, u.name, c.title, c.description, c.visibility, c.created,
+	(SELECT count(*) FROM collection_snippets cs WHERE cs.collection_id = c.id)
+	FROM collections c JOIN users u ON u.id = c.user_id`
+
+func scanCollection(row pgx.Row, c *Collection) error {
+	return row.Scan(&c.ID, &c.Slug, &c.UserID, &c.Author, &c.Title, &c.Description, &c.Visibility, &c.Created, &c.Size)
+}
+
+// Create an empty collection and return its slug.
+func (self *CollectionModel) Insert(userID int, title string, description string, visibility Visibility) (string, error) {
+	ctx := context.Background()
+
+	tx, err := self.DB.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	defer tx.Rollback(ctx)
+
+	stmt := `INSERT INTO collections (slug, user_id, title, description, visibility)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (slug) DO NOTHING returning id`
+
+	_, slug, err := insertWithSlug(ctx, tx, stmt, userID, title, description, visibility)
+	if err != nil {
+		return "", err
+	}
+
+	return slug, tx.Commit(ctx)
+}
+
+// Get a collection by its slug, with the same visibility rules as snippets,
+// together with the snippets in it that viewerID may see. Unlisted snippets
+// are only listed when they belong to the collection's owner, as anyone else
+// adding one has no say over who gets its link, and view-limited snippets
+// never are.
+func (self *CollectionModel) Get(slug string, viewerID int) (*Collection, error) {
+	stmt := collectionSelect + `
+	WHERE c.slug = $1 AND (c.visibility <> 'private' OR c.user_id = $2)`
+
+	c := &Collection{}
+
+	err := scanCollection(self.DB.QueryRow(context.Background(), stmt, slug, viewerID), c)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	stmt = snippetSelect + `
+	JOIN collection_snippets cs ON cs.snippet_id = s.id
+	WHERE cs.collection_id = $1 AND ` + liveSnippet + ` AND s.views_left IS NULL
+	AND (s.visibility = 'public' OR s.user_id = $3
+		OR (s.visibility = 'unlisted' AND s.user_id = $2))
+	ORDER BY cs.position, s.id`
+
+	snippets := &SnippetModel{DB: self.DB}
+
+	c.Snippets, err = snippets.query(stmt, c.ID, c.UserID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// All of a user's collections, by title.
+func (self *CollectionModel) ByOwner(userID int) ([]*Collection, error) {
+	stmt := collectionSelect + `
+	WHERE c.user_id = $1
+	ORDER BY c.title, c.id`
+
+	rows, err := self.DB.Query(context.Background(), stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	collections := []*Collection{}
+
+	for rows.Next() {
+		c := &Collection{}
+
+		err = scanCollection(rows, c)
+		if err != nil {
+			return nil, err
+		}
+
+		collections = append(collections, c)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return collections, nil
+}
+
+// Update the title, description and visibility of a collection owned by
+// userID.
+func (self *CollectionModel) Update(id int, userID int, title string, description string, visibility Visibility) error {
+	stmt := `UPDATE collections SET title = $3, description = $4, visibility = $5
+	WHERE id = $1 AND user_id = $2`
+
+	return self.exec(stmt, id, userID, title, description, visibility)
+}
+
+// Delete a collection owned by userID. The snippets in it are left alone.
+func (self *CollectionModel) Delete(id int, userID int) error {
+	stmt := `DELETE FROM collections WHERE id = $1 AND user_id = $2`
+
+	return self.exec(stmt, id, userID)
+}
+
+// Add a snippet to the end of a collection owned by userID. Adding a snippet
+// that's already there does nothing.
+func (self *CollectionModel) AddSnippet(id int, userID int, snippetID int) error {
+	ctx := context.Background()
+
+	tx, err := self.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	// Locking the collection keeps snippets added at the same time from
+	// getting the same position.
+	var locked int
+
+	stmt := `SELECT id FROM collections WHERE id = $1 AND user_id = $2 FOR UPDATE`
+
+	err = tx.QueryRow(ctx, stmt, id, userID).Scan(&locked)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	stmt = `INSERT INTO collection_snippets (collection_id, snippet_id, position)
+	SELECT $1, $2, coalesce(max(position), 0) + 1
+	FROM collection_snippets WHERE collection_id = $1
+	ON CONFLICT (collection_id, snippet_id) DO NOTHING`
+
+	_, err = tx.Exec(ctx, stmt, id, snippetID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Take a snippet out of a collection owned by userID.
+func (self *CollectionModel) RemoveSnippet(id int, userID int, snippetID int) error {
+	stmt := `DELETE FROM collection_snippets cs USING collections c
+	WHERE c.id = cs.collection_id AND c.id = $1 AND c.user_id = $2 AND cs.snippet_id = $3`
+
+	return self.exec(stmt, id, userID, snippetID)
+}
+
+// Move a snippet offset places towards the end of a collection owned by
+// userID, or towards the start when offset is negative. Snippets don't move
+// past either end.
+func (self *CollectionModel) MoveSnippet(id int, userID int, snippetID int, offset int) error {
+	ctx := context.Background()
+
+	tx, err := self.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	stmt := `SELECT cs.snippet_id FROM collection_snippets cs
+	JOIN collections c ON c.id = cs.collection_id
+	WHERE c.id = $1 AND c.user_id = $2
+	ORDER BY cs.position, cs.snippet_id
+	FOR UPDATE OF cs`
+
+	rows, err := tx.Query(ctx, stmt, id, userID)
+	if err != nil {
+		return err
+	}
+
+	order, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return err
+	}
+
+	from := slices.Index(order, snippetID)
+	if from == -1 {
+		return ErrNoRecord
+	}
+
+	to := max(0, min(len(order)-1, from+offset))
+
+	order = slices.Delete(order, from, from+1)
+	order = slices.Insert(order, to, snippetID)
+
+	// Number the snippets afresh, which also closes gaps left by removed
+	// ones.
+	stmt = `UPDATE collection_snippets cs SET position = o.position
+	FROM unnest($2::integer[]) WITH ORDINALITY AS o(snippet_id, position)
+	WHERE cs.collection_id = $1 AND cs.snippet_id = o.snippet_id`
+
+	_, err = tx.Exec(ctx, stmt, id, order)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Run a statement that must affect at least one row, returning ErrNoRecord
+// when it matched none.
+func (self *CollectionModel) exec(stmt string, args ...any) error {
+	tag, err := self.DB.Exec(context.Background(), stmt, args...)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
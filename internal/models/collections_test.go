@@ -0,0 +1,80 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"snippetbox.davc.io/internal/assert"
+)
+
+func TestCollectionModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	snippets := SnippetModel{db}
+	m := CollectionModel{db}
+
+	var ids []int
+	for _, title := range []string{"One", "Two", "Three"} {
+		slug, err := snippets.Insert(1, title, "", title, "plaintext", nil, VisibilityPublic, "", 0, Expiry{}, nil)
+		assert.NilError(t, err)
+
+		s, err := snippets.GetBySlug(slug, 0)
+		assert.NilError(t, err)
+
+		ids = append(ids, s.ID)
+	}
+
+	slug, err := m.Insert(1, "Haiku", "Short poems", VisibilityUnlisted)
+	assert.NilError(t, err)
+
+	collection, err := m.Get(slug, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, collection.Title, "Haiku")
+	assert.Equal(t, len(collection.Snippets), 0)
+
+	for _, id := range ids {
+		assert.NilError(t, m.AddSnippet(collection.ID, 1, id))
+	}
+
+	// Adding twice keeps the first place.
+	assert.NilError(t, m.AddSnippet(collection.ID, 1, ids[0]))
+
+	err = m.AddSnippet(collection.ID, 2, ids[0])
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	assert.NilError(t, m.MoveSnippet(collection.ID, 1, ids[2], -1))
+	assert.NilError(t, m.MoveSnippet(collection.ID, 1, ids[0], 5))
+
+	collection, err = m.Get(slug, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, collection.Size, 3)
+	assert.Equal(t, collection.Snippets[0].Title, "Three")
+	assert.Equal(t, collection.Snippets[1].Title, "Two")
+	assert.Equal(t, collection.Snippets[2].Title, "One")
+
+	assert.NilError(t, m.RemoveSnippet(collection.ID, 1, ids[1]))
+
+	err = m.Update(collection.ID, 1, "Haiku", "", VisibilityPrivate)
+	assert.NilError(t, err)
+
+	_, err = m.Get(slug, 0)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	collection, err = m.Get(slug, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(collection.Snippets), 2)
+
+	collections, err := m.ByOwner(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(collections), 1)
+	assert.Equal(t, collections[0].Size, 2)
+
+	assert.NilError(t, m.Delete(collection.ID, 1))
+
+	err = m.Delete(collection.ID, 1)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+}
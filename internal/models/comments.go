@@ -0,0 +1,232 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"time"
+
+	"snippetbox.davc.io/internal/markdown"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type CommentModelInterface interface {
+	Insert(snippetID int, userID int, parentID int, line string, body string) (int, error)
+	Get(id int) (*Comment, error)
+	ForSnippet(snippetID int) ([]*Comment, error)
+	Update(id int, userID int, body string) error
+	Delete(id int, userID int) error
+}
+
+// A comment on a snippet. Comments are either top level or replies to a top
+// level comment, never replies to replies.
+type Comment struct {
+	ID          int
+	SnippetID   int
+	SnippetSlug string
+	UserID      int
+	Author      string
+	// ID of the comment this one replies to, or nil for top level comments.
+	ParentID *int
+	// Anchor of the line of the snippet the comment is about, such as "L12"
+	// or "file-2-L3", or empty when it's about the whole snippet.
+	Line string
+	// Markdown source of the comment and the HTML it renders to.
+	Body     string
+	Rendered template.HTML
+	Created  time.Time
+	// When the comment was last edited, or nil when it never was.
+	Updated *time.Time
+	// Deleted comments are kept, without their text, while they have
+	// replies.
+	Deleted bool
+	// Replies, oldest first. Only filled in on top level comments by
+	// ForSnippet.
+	Replies []*Comment
+}
+
+type CommentModel struct {
+	DB *pgxpool.Pool
+}
+
+const commentSelect = `SELECT c.id, c.snippet_id, s.slug, c.user_id, u.name, c.parent_id, c.line,
+	c.body, c.rendered, c.created, c.updated, c.deleted_at IS NOT NULL
+	FROM comments c
+	JOIN snippets s ON s.id = c.snippet_id
+	JOIN users u ON u.id = c.user_id`
+
+func scanComment(row pgx.Row, c *Comment) error {
+	return row.Scan(&c.ID, &c.SnippetID, &c.SnippetSlug, &c.UserID, &c.Author, &c.ParentID, &c.Line,
+		&c.Body, &c.Rendered, &c.Created, &c.Updated, &c.Deleted)
+}
+
+// Add a comment to a snippet and return its ID. A parentID of 0 makes a top
+// level comment. Replies to a reply are added to the comment it replies to,
+// and a parent that isn't a comment on the snippet, or has been deleted,
+// gives ErrNoRecord.
+func (self *CommentModel) Insert(snippetID int, userID int, parentID int, line string, body string) (int, error) {
+	rendered, err := markdown.RenderLite(body)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int
+
+	if parentID == 0 {
+		stmt := `INSERT INTO comments (snippet_id, user_id, line, body, rendered)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`
+
+		err = self.DB.QueryRow(context.Background(), stmt, snippetID, userID, line, body, rendered).Scan(&id)
+		return id, err
+	}
+
+	stmt := `INSERT INTO comments (snippet_id, user_id, parent_id, line, body, rendered)
+	SELECT $1, $2, coalesce(p.parent_id, p.id), $4, $5, $6
+	FROM comments p WHERE p.id = $3 AND p.snippet_id = $1 AND p.deleted_at IS NULL
+	RETURNING id`
+
+	err = self.DB.QueryRow(context.Background(), stmt, snippetID, userID, parentID, line, body, rendered).Scan(&id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// Get a comment that hasn't been deleted on a live snippet.
+func (self *CommentModel) Get(id int) (*Comment, error) {
+	stmt := commentSelect + `
+	WHERE ` + liveSnippet + ` AND c.id = $1 AND c.deleted_at IS NULL`
+
+	c := &Comment{}
+
+	err := scanComment(self.DB.QueryRow(context.Background(), stmt, id), c)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Top level comments on a snippet with their replies, oldest first.
+func (self *CommentModel) ForSnippet(snippetID int) ([]*Comment, error) {
+	stmt := commentSelect + `
+	WHERE c.snippet_id = $1
+	ORDER BY c.created, c.id`
+
+	rows, err := self.DB.Query(context.Background(), stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	comments := []*Comment{}
+	byID := map[int]*Comment{}
+
+	for rows.Next() {
+		c := &Comment{}
+
+		err = scanComment(rows, c)
+		if err != nil {
+			return nil, err
+		}
+
+		// Parents are always older than their replies, so they've been
+		// seen already.
+		if c.ParentID == nil {
+			comments = append(comments, c)
+			byID[c.ID] = c
+		} else if parent, ok := byID[*c.ParentID]; ok {
+			parent.Replies = append(parent.Replies, c)
+		}
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// Replace the text of a comment written by userID.
+func (self *CommentModel) Update(id int, userID int, body string) error {
+	rendered, err := markdown.RenderLite(body)
+	if err != nil {
+		return err
+	}
+
+	stmt := `UPDATE comments SET body = $3, rendered = $4, updated = now()
+	WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL`
+
+	tag, err := self.DB.Exec(context.Background(), stmt, id, userID, body, rendered)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// Delete a comment written by userID. A comment with replies loses its text
+// but stays in place so the replies still make sense, until the last of them
+// is deleted too.
+func (self *CommentModel) Delete(id int, userID int) error {
+	ctx := context.Background()
+
+	tx, err := self.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	stmt := `DELETE FROM comments c
+	WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+	AND NOT EXISTS (SELECT 1 FROM comments r WHERE r.parent_id = c.id)
+	RETURNING parent_id`
+
+	var parentID *int
+
+	err = tx.QueryRow(ctx, stmt, id, userID).Scan(&parentID)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		stmt = `UPDATE comments SET body = '', rendered = '', line = '', deleted_at = now()
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL`
+
+		tag, err := tx.Exec(ctx, stmt, id, userID)
+		if err != nil {
+			return err
+		}
+
+		if tag.RowsAffected() == 0 {
+			return ErrNoRecord
+		}
+	case err != nil:
+		return err
+	case parentID != nil:
+		stmt = `DELETE FROM comments p
+		WHERE id = $1 AND deleted_at IS NOT NULL
+		AND NOT EXISTS (SELECT 1 FROM comments r WHERE r.parent_id = p.id)`
+
+		_, err = tx.Exec(ctx, stmt, *parentID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"snippetbox.davc.io/internal/assert"
+)
+
+func TestCommentModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	snippets := SnippetModel{db}
+	m := CommentModel{db}
+
+	slug, err := snippets.Insert(1, "Hello", "", "Hello, world", "plaintext", nil, VisibilityPublic, "", 0, Expiry{}, nil)
+	assert.NilError(t, err)
+
+	snippet, err := snippets.GetBySlug(slug, 0)
+	assert.NilError(t, err)
+
+	parentID, err := m.Insert(snippet.ID, 1, 0, "L1", "Why **hello**?")
+	assert.NilError(t, err)
+
+	replyID, err := m.Insert(snippet.ID, 1, parentID, "", "Because.")
+	assert.NilError(t, err)
+
+	// Replies to replies go to the top level comment.
+	_, err = m.Insert(snippet.ID, 1, replyID, "", "Fair enough.")
+	assert.NilError(t, err)
+
+	_, err = m.Insert(snippet.ID, 1, 999, "", "Hello?")
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	comments, err := m.ForSnippet(snippet.ID)
+	assert.NilError(t, err)
+
+	assert.Equal(t, len(comments), 1)
+	assert.Equal(t, comments[0].Line, "L1")
+	assert.Equal(t, string(comments[0].Rendered), "<p>Why <strong>hello</strong>?</p>\n")
+	assert.Equal(t, len(comments[0].Replies), 2)
+
+	err = m.Update(replyID, 1, "Because I said so.")
+	assert.NilError(t, err)
+
+	reply, err := m.Get(replyID)
+	assert.NilError(t, err)
+	assert.Equal(t, reply.Body, "Because I said so.")
+	assert.Equal(t, reply.Updated != nil, true)
+
+	err = m.Update(replyID, 2, "Hijacked")
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	// The parent keeps its place while it has replies.
+	err = m.Delete(parentID, 1)
+	assert.NilError(t, err)
+
+	comments, err = m.ForSnippet(snippet.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 1)
+	assert.Equal(t, comments[0].Deleted, true)
+	assert.Equal(t, comments[0].Body, "")
+
+	for _, r := range comments[0].Replies {
+		err = m.Delete(r.ID, 1)
+		assert.NilError(t, err)
+	}
+
+	comments, err = m.ForSnippet(snippet.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, len(comments), 0)
+}
@@ -10,4 +10,10 @@ var (
 	ErrInvalidCredentials = errors.New("models: invalid credentials")
 
 	ErrDuplicateEmail = errors.New("models: duplicate email")
+
+	ErrInvalidCursor = errors.New("models: invalid cursor")
+
+	ErrUnsupportedFilter = errors.New("models: unsupported search filter")
+
+	ErrSlugCollision = errors.New("models: couldn't generate a unique slug")
 )
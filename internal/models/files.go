@@ -0,0 +1,91 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// A file of a multi-file snippet other than its first, whose filename,
+// language and content are kept on the snippet itself.
+type File struct {
+	// Where the file comes in its snippet, counting from 2 since the
+	// snippet's own content is the first file.
+	Position int
+	Filename string
+	// Language is a name from highlight.Languages, or one guessed by the
+	// highlighter.
+	Language    string
+	Content     string
+	Highlighted template.HTML
+}
+
+// Anchor returns the id of the file on its snippet's page, which also
+// prefixes the anchors of its lines.
+func (f *File) Anchor() string {
+	return fmt.Sprintf("file-%d", f.Position)
+}
+
+// Render and save the files of a snippet after its first, replacing any it
+// had. Languages may be highlight.Auto.
+func setFiles(ctx context.Context, tx pgx.Tx, snippetID int, files []*File) error {
+	_, err := tx.Exec(ctx, "DELETE FROM snippet_files WHERE snippet_id = $1", snippetID)
+	if err != nil {
+		return err
+	}
+
+	stmt := `INSERT INTO snippet_files (snippet_id, position, filename, language, content, highlighted)
+	VALUES ($1, $2, $3, $4, $5, $6)`
+
+	for i, f := range files {
+		f.Position = i + 2
+
+		highlighted, language, err := render(f.Language, f.Content, f.Anchor()+"-L")
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, stmt, snippetID, f.Position, f.Filename, language, f.Content, highlighted)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// The files of a snippet after its first, in order.
+func (self *SnippetModel) files(snippetID int) ([]*File, error) {
+	stmt := `SELECT position, filename, language, content, highlighted FROM snippet_files
+	WHERE snippet_id = $1
+	ORDER BY position`
+
+	rows, err := self.DB.Query(context.Background(), stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	files := []*File{}
+
+	for rows.Next() {
+		f := &File{}
+
+		err = rows.Scan(&f.Position, &f.Filename, &f.Language, &f.Content, &f.Highlighted)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, f)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
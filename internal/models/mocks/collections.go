@@ -0,0 +1,97 @@
+package mocks
+
+import (
+	"time"
+
+	"snippetbox.davc.io/internal/models"
+)
+
+var mockCollection = &models.Collection{
+	ID:          1,
+	Slug:        "Cl5vR8nWqT",
+	UserID:      1,
+	Author:      "Alice",
+	Title:       "Poems and code",
+	Description: "Things worth keeping.",
+	Visibility:  models.VisibilityPublic,
+	Created:     time.Now(),
+	Size:        5,
+}
+
+var mockPrivateCollection = &models.Collection{
+	ID:         2,
+	Slug:       "Cp3xH7kMzD",
+	UserID:     1,
+	Author:     "Alice",
+	Title:      "Drafts",
+	Visibility: models.VisibilityPrivate,
+	Created:    time.Now(),
+}
+
+type CollectionModel struct{}
+
+func (m *CollectionModel) Insert(userID int, title string, description string, visibility models.Visibility) (string, error) {
+	return "Cn2wB6rTyU", nil
+}
+
+func (m *CollectionModel) Get(slug string, viewerID int) (*models.Collection, error) {
+	switch slug {
+	case mockCollection.Slug:
+		c := *mockCollection
+		c.Snippets = []*models.Snippet{mockSnippet, mockMultiFileSnippet, mockProtectedSnippet}
+		if viewerID == mockPrivateSnippet.UserID {
+			c.Snippets = append(c.Snippets, mockPrivateSnippet)
+		}
+		return &c, nil
+	case mockPrivateCollection.Slug:
+		if viewerID != mockPrivateCollection.UserID {
+			return nil, models.ErrNoRecord
+		}
+		c := *mockPrivateCollection
+		c.Snippets = []*models.Snippet{}
+		return &c, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *CollectionModel) ByOwner(userID int) ([]*models.Collection, error) {
+	if userID == 1 {
+		return []*models.Collection{mockPrivateCollection, mockCollection}, nil
+	}
+	return []*models.Collection{}, nil
+}
+
+func (m *CollectionModel) Update(id int, userID int, title string, description string, visibility models.Visibility) error {
+	return owned(id, userID)
+}
+
+func (m *CollectionModel) Delete(id int, userID int) error {
+	return owned(id, userID)
+}
+
+func (m *CollectionModel) AddSnippet(id int, userID int, snippetID int) error {
+	return owned(id, userID)
+}
+
+func (m *CollectionModel) RemoveSnippet(id int, userID int, snippetID int) error {
+	if id == mockCollection.ID && snippetID != mockSnippet.ID {
+		return models.ErrNoRecord
+	}
+	return owned(id, userID)
+}
+
+func (m *CollectionModel) MoveSnippet(id int, userID int, snippetID int, offset int) error {
+	if id == mockCollection.ID && snippetID != mockSnippet.ID {
+		return models.ErrNoRecord
+	}
+	return owned(id, userID)
+}
+
+// Report ErrNoRecord unless userID owns the mock collection with the ID.
+func owned(id int, userID int) error {
+	if (id == mockCollection.ID || id == mockPrivateCollection.ID) && userID == 1 {
+		return nil
+	}
+	return models.ErrNoRecord
+}
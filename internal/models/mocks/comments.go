@@ -0,0 +1,77 @@
+package mocks
+
+import (
+	"time"
+
+	"snippetbox.davc.io/internal/models"
+)
+
+var mockCommentParentID = 1
+
+var mockReply = &models.Comment{
+	ID:          2,
+	SnippetID:   1,
+	SnippetSlug: "Bq3xT7nLwP",
+	UserID:      2,
+	Author:      "Bob",
+	ParentID:    &mockCommentParentID,
+	Body:        "It's a haiku.",
+	Rendered:    "<p>It&#39;s a haiku.</p>\n",
+	Created:     time.Now(),
+}
+
+var mockComment = &models.Comment{
+	ID:          1,
+	SnippetID:   1,
+	SnippetSlug: "Bq3xT7nLwP",
+	UserID:      1,
+	Author:      "Alice",
+	Line:        "L1",
+	Body:        "Why a **frog**?",
+	Rendered:    "<p>Why a <strong>frog</strong>?</p>\n",
+	Created:     time.Now(),
+	Replies:     []*models.Comment{mockReply},
+}
+
+type CommentModel struct{}
+
+func (m *CommentModel) Insert(snippetID int, userID int, parentID int, line string, body string) (int, error) {
+	switch parentID {
+	case 0, mockComment.ID, mockReply.ID:
+		return 3, nil
+	default:
+		return 0, models.ErrNoRecord
+	}
+}
+
+func (m *CommentModel) Get(id int) (*models.Comment, error) {
+	switch id {
+	case mockComment.ID:
+		return mockComment, nil
+	case mockReply.ID:
+		return mockReply, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *CommentModel) ForSnippet(snippetID int) ([]*models.Comment, error) {
+	if snippetID == mockSnippet.ID {
+		return []*models.Comment{mockComment}, nil
+	}
+	return []*models.Comment{}, nil
+}
+
+func (m *CommentModel) Update(id int, userID int, body string) error {
+	if id == mockComment.ID && userID == mockComment.UserID {
+		return nil
+	}
+	return models.ErrNoRecord
+}
+
+func (m *CommentModel) Delete(id int, userID int) error {
+	if id == mockComment.ID && userID == mockComment.UserID {
+		return nil
+	}
+	return models.ErrNoRecord
+}
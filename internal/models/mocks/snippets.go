@@ -4,31 +4,329 @@ import (
 	"time"
 
 	"snippetbox.davc.io/internal/models"
+	"snippetbox.davc.io/internal/query"
 )
 
+var mockExpires = time.Now().Add(7 * 24 * time.Hour)
+
 var mockSnippet = &models.Snippet{
-	ID:      1,
-	Title:   "An old silent pond",
-	Content: "An old silent pond...",
+	ID:          1,
+	Slug:        "Bq3xT7nLwP",
+	UserID:      1,
+	Author:      "Alice",
+	Title:       "An old silent pond",
+	Content:     "An old silent pond...",
+	Language:    "plaintext",
+	Highlighted: `<pre class="chroma"><code><span class="line"><span class="cl">An old silent pond...</span></span></code></pre>`,
+	Visibility:  models.VisibilityPublic,
+	Created:     time.Now(),
+	Expires:     &mockExpires,
+	Forks:       1,
+	Stars:       2,
+	Tags:        []string{"haiku"},
+}
+
+var mockForkedFrom = 1
+
+var mockForkedFromSlug = "Bq3xT7nLwP"
+
+var mockFork = &models.Snippet{
+	ID:             9,
+	Slug:           "Fk9pW3sLmV",
+	UserID:         2,
+	Author:         "Bob",
+	Title:          "An old silent pond, again",
+	Content:        "An old silent pond...",
+	Language:       "plaintext",
+	Visibility:     models.VisibilityPublic,
+	Created:        time.Now(),
+	Expires:        &mockExpires,
+	ForkedFrom:     &mockForkedFrom,
+	ForkedFromSlug: &mockForkedFromSlug,
+	Stars:          1,
+}
+
+var mockPrivateSnippet = &models.Snippet{
+	ID:         4,
+	Slug:       "Zr4kP8dQmT",
+	UserID:     1,
+	Author:     "Alice",
+	Title:      "A private note",
+	Content:    "For my eyes only",
+	Language:   "plaintext",
+	Visibility: models.VisibilityPrivate,
+	Created:    time.Now(),
+	Expires:    &mockExpires,
+}
+
+var mockUnlistedSnippet = &models.Snippet{
+	ID:         5,
+	Slug:       "Hn6tY2cVbX",
+	UserID:     1,
+	Author:     "Alice",
+	Title:      "An unlisted note",
+	Content:    "For whoever has the link",
+	Language:   "plaintext",
+	Visibility: models.VisibilityUnlisted,
+	Created:    time.Now(),
+	Expires:    &mockExpires,
+}
+
+var mockProtectedSnippet = &models.Snippet{
+	ID:         6,
+	Slug:       "Pw7dK3mNqZ",
+	UserID:     1,
+	Author:     "Alice",
+	Title:      "Staging credentials",
+	Content:    "user: admin",
+	Language:   "plaintext",
+	Visibility: models.VisibilityUnlisted,
+	Protected:  true,
+	Created:    time.Now(),
+	Expires:    &mockExpires,
+}
+
+var mockViewsLeft = 1
+
+var mockBurnSnippet = &models.Snippet{
+	ID:         7,
+	Slug:       "Bn8rA4tLeX",
+	UserID:     1,
+	Author:     "Alice",
+	Title:      "One-time token",
+	Content:    "token: 5f3c",
+	Language:   "plaintext",
+	Visibility: models.VisibilityUnlisted,
+	ViewsLeft:  &mockViewsLeft,
+	Created:    time.Now(),
+	Expires:    &mockExpires,
+}
+
+// A view-limited snippet whose last view is taken by someone else between
+// looking it up and consuming a view.
+var mockSpentSnippet = &models.Snippet{
+	ID:         8,
+	Slug:       "Sp2nT6wQeR",
+	UserID:     1,
+	Author:     "Alice",
+	Title:      "Another one-time token",
+	Content:    "token: 9a1d",
+	Language:   "plaintext",
+	Visibility: models.VisibilityUnlisted,
+	ViewsLeft:  &mockViewsLeft,
+	Created:    time.Now(),
+	Expires:    &mockExpires,
+}
+
+var mockMultiFileSnippet = &models.Snippet{
+	ID:          10,
+	Slug:        "Mf4tG8hJkL",
+	UserID:      1,
+	Author:      "Alice",
+	Title:       "Hello module",
+	Filename:    "main.go",
+	Content:     "package main",
+	Language:    "go",
+	Highlighted: `<pre class="chroma"><code><span class="line"><span class="ln" id="L1"><a class="lnlinks" href="#L1">1</a></span><span class="cl">package main</span></span></code></pre>`,
+	Visibility:  models.VisibilityPublic,
+	Files: []*models.File{
+		{
+			Position:    2,
+			Filename:    "go.mod",
+			Language:    "plaintext",
+			Content:     "module hello",
+			Highlighted: `<pre class="chroma"><code><span class="line"><span class="ln" id="file-2-L1"><a class="lnlinks" href="#file-2-L1">1</a></span><span class="cl">module hello</span></span></code></pre>`,
+		},
+	},
+	Created: time.Now(),
+	Expires: &mockExpires,
+}
+
+var mockTrashedSnippet = &models.Snippet{
+	ID:      3,
+	Slug:    "Wf5cNs9GjA",
+	UserID:  1,
+	Author:  "Alice",
+	Title:   "First autumn morning",
+	Content: "First autumn morning...",
 	Created: time.Now(),
-	Expires: time.Now(),
+	Expires: &mockExpires,
+}
+
+var mockRevisions = []*models.Revision{
+	{
+		ID:        2,
+		SnippetID: 1,
+		Version:   2,
+		UserID:    1,
+		Author:    "Alice",
+		Title:     "An old silent pond",
+		Content:   "An old silent pond...",
+		Created:   time.Now(),
+	},
+	{
+		ID:        1,
+		SnippetID: 1,
+		Version:   1,
+		UserID:    1,
+		Author:    "Alice",
+		Title:     "An old pond",
+		Content:   "An old pond...",
+		Created:   time.Now(),
+	},
 }
 
+var mockLiveSnippets = []*models.Snippet{mockSnippet, mockFork, mockPrivateSnippet, mockUnlistedSnippet, mockProtectedSnippet, mockBurnSnippet, mockSpentSnippet, mockMultiFileSnippet}
+
 type SnippetModel struct{}
 
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
-	return 2, nil
+func (m *SnippetModel) Insert(userID int, title string, filename string, content string, language string, files []*models.File, visibility models.Visibility, password string, maxViews int, expires models.Expiry, tags []string) (string, error) {
+	return "Xm2pL9sRtA", nil
+}
+
+func (m *SnippetModel) Fork(id int, userID int) (string, error) {
+	return "Fk3mQ7vBnZ", nil
 }
 
-func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+func (m *SnippetModel) Forks(id int, limit int) ([]*models.Snippet, error) {
 	switch id {
 	case 1:
-		return mockSnippet, nil
+		return []*models.Snippet{mockFork}, nil
+	default:
+		return []*models.Snippet{}, nil
+	}
+}
+
+func (m *SnippetModel) Get(id int, viewerID int) (*models.Snippet, error) {
+	for _, snippet := range mockLiveSnippets {
+		if snippet.ID == id {
+			return visible(snippet, viewerID)
+		}
+	}
+	return nil, models.ErrNoRecord
+}
+
+func (m *SnippetModel) GetBySlug(slug string, viewerID int) (*models.Snippet, error) {
+	for _, snippet := range mockLiveSnippets {
+		if snippet.Slug == slug {
+			return visible(snippet, viewerID)
+		}
+	}
+	return nil, models.ErrNoRecord
+}
+
+func (m *SnippetModel) CheckPassword(id int, password string) error {
+	switch {
+	case id != mockProtectedSnippet.ID:
+		return models.ErrNoRecord
+	case password != "open sesame":
+		return models.ErrInvalidCredentials
 	default:
+		return nil
+	}
+}
+
+func (m *SnippetModel) ConsumeView(id int) (int, error) {
+	if id == mockBurnSnippet.ID {
+		return 0, nil
+	}
+	return 0, models.ErrNoRecord
+}
+
+// Return a copy of the snippet, so handlers can't change the shared mocks,
+// unless the viewer isn't allowed to see it.
+func visible(snippet *models.Snippet, viewerID int) (*models.Snippet, error) {
+	if snippet.Visibility == models.VisibilityPrivate && snippet.UserID != viewerID {
 		return nil, models.ErrNoRecord
 	}
+	clone := *snippet
+	return &clone, nil
+}
+
+func (m *SnippetModel) Latest(cursor string, size int) (*models.SnippetPage, error) {
+	if cursor != "" {
+		_, err := models.ParseCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		return &models.SnippetPage{Snippets: []*models.Snippet{}}, nil
+	}
+	return &models.SnippetPage{Snippets: []*models.Snippet{mockSnippet}}, nil
+}
+
+func (m *SnippetModel) ByOwner(userID int, cursor string, size int) (*models.SnippetPage, error) {
+	switch userID {
+	case 1:
+		return &models.SnippetPage{Snippets: []*models.Snippet{mockSnippet}}, nil
+	default:
+		return &models.SnippetPage{Snippets: []*models.Snippet{}}, nil
+	}
+}
+
+func (m *SnippetModel) Update(id int, userID int, title string, filename string, content string, language string, files []*models.File, visibility models.Visibility, tags []string) error {
+	if id == 1 && userID == 1 {
+		return nil
+	}
+	return models.ErrNoRecord
+}
+
+func (m *SnippetModel) Revisions(id int) ([]*models.Revision, error) {
+	switch id {
+	case 1:
+		return mockRevisions, nil
+	default:
+		return []*models.Revision{}, nil
+	}
+}
+
+func (m *SnippetModel) Delete(id int, userID int) error {
+	if id == 1 && userID == 1 {
+		return nil
+	}
+	return models.ErrNoRecord
+}
+
+func (m *SnippetModel) Trash(userID int) ([]*models.Snippet, error) {
+	switch userID {
+	case 1:
+		return []*models.Snippet{mockTrashedSnippet}, nil
+	default:
+		return []*models.Snippet{}, nil
+	}
+}
+
+func (m *SnippetModel) Restore(id int, userID int) error {
+	if id == 3 && userID == 1 {
+		return nil
+	}
+	return models.ErrNoRecord
 }
 
-func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
-	return []*models.Snippet{mockSnippet}, nil
+func (m *SnippetModel) Purge(id int, userID int) error {
+	if id == 3 && userID == 1 {
+		return nil
+	}
+	return models.ErrNoRecord
+}
+
+func (m *SnippetModel) Search(q *query.Query, page int, size int) (*models.SearchResults, error) {
+	results := &models.SearchResults{Results: []*models.SearchResult{}, Page: page}
+
+	if q.String() == "pond" && page == 1 {
+		results.Results = append(results.Results, &models.SearchResult{
+			Snippet:  mockSnippet,
+			Headline: "An old silent " + models.HighlightStart + "pond" + models.HighlightStop + "...",
+		})
+	}
+
+	return results, nil
+}
+
+func (m *SnippetModel) ByTag(tag string, cursor string, size int) (*models.SnippetPage, error) {
+	switch tag {
+	case "haiku":
+		return &models.SnippetPage{Snippets: []*models.Snippet{mockSnippet}}, nil
+	default:
+		return &models.SnippetPage{Snippets: []*models.Snippet{}}, nil
+	}
 }
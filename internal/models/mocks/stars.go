@@ -0,0 +1,37 @@
+package mocks
+
+import (
+	"snippetbox.davc.io/internal/models"
+)
+
+// The mock user has starred the mock fork.
+type StarModel struct{}
+
+func (m *StarModel) Star(userID int, snippetID int) error {
+	return nil
+}
+
+func (m *StarModel) Unstar(userID int, snippetID int) error {
+	return nil
+}
+
+func (m *StarModel) Starred(userID int, snippetID int) (bool, error) {
+	return userID == 1 && snippetID == mockFork.ID, nil
+}
+
+func (m *StarModel) ByUser(userID int, cursor string, size int) (*models.SnippetPage, error) {
+	if cursor != "" {
+		_, err := models.ParseCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		return &models.SnippetPage{Snippets: []*models.Snippet{}}, nil
+	}
+
+	switch userID {
+	case 1:
+		return &models.SnippetPage{Snippets: []*models.Snippet{mockFork}}, nil
+	default:
+		return &models.SnippetPage{Snippets: []*models.Snippet{}}, nil
+	}
+}
@@ -0,0 +1,23 @@
+package mocks
+
+import (
+	"strings"
+
+	"snippetbox.davc.io/internal/models"
+)
+
+type TagModel struct{}
+
+func (m *TagModel) Suggest(prefix string, limit int) ([]string, error) {
+	tags := []string{}
+	for _, tag := range []string{"haiku", "hash"} {
+		if strings.HasPrefix(tag, prefix) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
+
+func (m *TagModel) Cloud(limit int) ([]*models.TagCount, error) {
+	return []*models.TagCount{{Name: "haiku", Count: 1, Weight: 1}}, nil
+}
@@ -0,0 +1,53 @@
+package mocks
+
+import (
+	"sync"
+	"time"
+
+	"snippetbox.davc.io/internal/models"
+)
+
+// Keeps the views it's given so that tests can check what was recorded.
+type ViewModel struct {
+	mu    sync.Mutex
+	views []*models.View
+}
+
+func (m *ViewModel) Insert(views []*models.View) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.views = append(m.views, views...)
+	return nil
+}
+
+// Views inserted so far.
+func (m *ViewModel) Views() []*models.View {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]*models.View(nil), m.views...)
+}
+
+func (m *ViewModel) Daily(snippetID int, days int) ([]*models.DailyViews, error) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	daily := []*models.DailyViews{}
+	for i := days - 1; i >= 0; i-- {
+		daily = append(daily, &models.DailyViews{Day: today.AddDate(0, 0, -i)})
+	}
+
+	if snippetID == mockSnippet.ID && len(daily) > 0 {
+		daily[len(daily)-1].Views = 3
+		daily[len(daily)-1].Visitors = 2
+	}
+
+	return daily, nil
+}
+
+func (m *ViewModel) Referrers(snippetID int, days int, limit int) ([]*models.ReferrerCount, error) {
+	if snippetID == mockSnippet.ID {
+		return []*models.ReferrerCount{{Host: "news.ycombinator.com", Views: 2}}, nil
+	}
+	return []*models.ReferrerCount{}, nil
+}
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Largest number of snippets a single page may hold.
+const MaxPageSize = 100
+
+// A position in a snippet listing, which is always ordered newest first by
+// (created, id). Clients only ever see cursors as opaque strings.
+type Cursor struct {
+	Created time.Time
+	ID      int
+	// Before selects the page preceding this position instead of the page
+	// following it.
+	Before bool
+}
+
+// One page of a snippet listing. Next and Prev are the cursors of the
+// neighbouring pages, or empty when there is no such page.
+type SnippetPage struct {
+	Snippets []*Snippet
+	Next     string
+	Prev     string
+}
+
+func (c Cursor) String() string {
+	direction := "a"
+	if c.Before {
+		direction = "b"
+	}
+
+	raw := fmt.Sprintf("%s:%d:%d", direction, c.Created.UnixMicro(), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// Decode a cursor produced by Cursor.String.
+func ParseCursor(s string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 || (parts[0] != "a" && parts[0] != "b") {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	created, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	id, err := strconv.Atoi(parts[2])
+	if err != nil || id < 1 {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	return Cursor{
+		Created: time.UnixMicro(created).UTC(),
+		ID:      id,
+		Before:  parts[0] == "b",
+	}, nil
+}
+
+// Fetch one page of snippets matching where, a condition on the aliased
+// snippets table "s" that uses args as its first placeholders. An empty
+// cursor selects the first page.
+func (self *SnippetModel) page(where string, args []any, cursor string, size int) (*SnippetPage, error) {
+	size = max(min(size, MaxPageSize), 1)
+
+	var c Cursor
+	if cursor != "" {
+		var err error
+
+		c, err = ParseCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		if c.Before {
+			where += fmt.Sprintf(" AND (s.created, s.id) > ($%d, $%d)", len(args)+1, len(args)+2)
+		} else {
+			where += fmt.Sprintf(" AND (s.created, s.id) < ($%d, $%d)", len(args)+1, len(args)+2)
+		}
+		args = append(args, c.Created, c.ID)
+	}
+
+	// Walking backwards means reading in ascending order from the cursor
+	// and reversing the result afterwards.
+	order := "s.created DESC, s.id DESC"
+	if c.Before {
+		order = "s.created ASC, s.id ASC"
+	}
+
+	// Ask for one extra row to find out whether there's a further page.
+	stmt := fmt.Sprintf("%s\n\tWHERE %s ORDER BY %s LIMIT %d", snippetSelect, where, order, size+1)
+
+	snippets, err := self.query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	more := len(snippets) > size
+	if more {
+		snippets = snippets[:size]
+	}
+
+	if c.Before {
+		for i, j := 0, len(snippets)-1; i < j; i, j = i+1, j-1 {
+			snippets[i], snippets[j] = snippets[j], snippets[i]
+		}
+	}
+
+	page := &SnippetPage{Snippets: snippets}
+	if len(snippets) == 0 {
+		return page, nil
+	}
+
+	first, last := snippets[0], snippets[len(snippets)-1]
+
+	if (c.Before && more) || (!c.Before && cursor != "") {
+		page.Prev = Cursor{Created: first.Created, ID: first.ID, Before: true}.String()
+	}
+	if (!c.Before && more) || c.Before {
+		page.Next = Cursor{Created: last.Created, ID: last.ID}.String()
+	}
+
+	return page, nil
+}
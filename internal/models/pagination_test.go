@@ -0,0 +1,52 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"snippetbox.davc.io/internal/assert"
+)
+
+func TestCursor(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor Cursor
+	}{
+		{
+			name:   "After",
+			cursor: Cursor{Created: time.Date(2024, 1, 2, 3, 4, 5, 6000, time.UTC), ID: 42},
+		},
+		{
+			name:   "Before",
+			cursor: Cursor{Created: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), ID: 7, Before: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseCursor(tt.cursor.String())
+
+			assert.NilError(t, err)
+			assert.Equal(t, c, tt.cursor)
+		})
+	}
+}
+
+func TestParseCursorInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "Not base64", cursor: "%%%"},
+		{name: "Missing parts", cursor: "YToxMjM"},
+		{name: "Bad direction", cursor: "eDoxMjM6NA"},
+		{name: "Zero ID", cursor: "YToxMjM6MA"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseCursor(tt.cursor)
+			assert.Equal(t, err, ErrInvalidCursor)
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ReaperModelInterface interface {
+	PurgeSnippets(grace time.Duration, limit int) (int, error)
+	PurgeTags(limit int) (int, error)
+	PurgeSessions(limit int) (int, error)
+}
+
+// Deletes rows that nothing can see any more. Each method removes at most
+// limit rows, so a large backlog is cleared in batches that keep locks short,
+// and returns how many it removed.
+type ReaperModel struct {
+	DB *pgxpool.Pool
+}
+
+// Delete snippets that expired more than grace ago, or that ran out of views,
+// together with their revisions and tags. There's no telling when a snippet's
+// last view was, so those are deleted without waiting.
+func (self *ReaperModel) PurgeSnippets(grace time.Duration, limit int) (int, error) {
+	stmt := `DELETE FROM snippets WHERE id IN (
+		SELECT id FROM snippets
+		WHERE expires < now() - make_interval(secs => $1) OR views_left = 0
+		LIMIT $2)`
+
+	tag, err := self.DB.Exec(context.Background(), stmt, grace.Seconds(), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(tag.RowsAffected()), nil
+}
+
+// Delete tags that no snippet carries.
+func (self *ReaperModel) PurgeTags(limit int) (int, error) {
+	stmt := `DELETE FROM tags WHERE id IN (
+		SELECT t.id FROM tags t
+		WHERE NOT EXISTS (SELECT 1 FROM snippet_tags st WHERE st.tag_id = t.id)
+		LIMIT $1)`
+
+	tag, err := self.DB.Exec(context.Background(), stmt, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(tag.RowsAffected()), nil
+}
+
+// Delete expired sessions.
+func (self *ReaperModel) PurgeSessions(limit int) (int, error) {
+	stmt := `DELETE FROM sessions WHERE token IN (
+		SELECT token FROM sessions WHERE expiry < now() LIMIT $1)`
+
+	tag, err := self.DB.Exec(context.Background(), stmt, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(tag.RowsAffected()), nil
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"snippetbox.davc.io/internal/assert"
+	"snippetbox.davc.io/internal/highlight"
+)
+
+func TestReaperModelPurgeSnippets(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	snippets := SnippetModel{db}
+	m := ReaperModel{db}
+
+	insert := func(maxViews int, expires Expiry) int {
+		t.Helper()
+
+		slug, err := snippets.Insert(1, "Title", "", "Content", highlight.PlainText, nil, VisibilityPublic, "", maxViews, expires, []string{"go"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var id int
+		err = db.QueryRow(context.Background(), "SELECT id FROM snippets WHERE slug = $1", slug).Scan(&id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	insert(0, Expiry{Minutes: 60})
+	insert(0, Expiry{At: time.Now().Add(-time.Minute)})
+	insert(0, Expiry{At: time.Now().Add(-2 * time.Hour)})
+	usedUp := insert(1, Expiry{})
+
+	_, err := snippets.ConsumeView(usedUp)
+	assert.NilError(t, err)
+
+	// The snippet that expired a minute ago is still within the grace period.
+	n, err := m.PurgeSnippets(time.Hour, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, n, 1)
+
+	n, err = m.PurgeSnippets(time.Hour, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, n, 1)
+
+	n, err = m.PurgeSnippets(time.Hour, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, n, 0)
+
+	n, err = m.PurgeTags(10)
+	assert.NilError(t, err)
+	assert.Equal(t, n, 0)
+
+	n, err = m.PurgeSnippets(0, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, n, 1)
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// A saved version of a snippet. Version numbers start at 1 for the content
+// the snippet was created with.
+type Revision struct {
+	ID        int
+	SnippetID int
+	Version   int
+	UserID    int
+	Author    string
+	Title     string
+	Content   string
+	Created   time.Time
+}
+
+func insertRevision(ctx context.Context, tx pgx.Tx, snippetID int, userID int, title string, content string) error {
+	stmt := `INSERT INTO snippet_revisions (snippet_id, version, user_id, title, content)
+	SELECT $1, coalesce(max(version), 0) + 1, $2, $3, $4
+	FROM snippet_revisions WHERE snippet_id = $1`
+
+	_, err := tx.Exec(ctx, stmt, snippetID, userID, title, content)
+	return err
+}
+
+// All revisions of a snippet, newest first.
+func (self *SnippetModel) Revisions(id int) ([]*Revision, error) {
+	stmt := `SELECT r.id, r.snippet_id, r.version, r.user_id, u.name, r.title, r.content, r.created
+	FROM snippet_revisions r
+	JOIN snippets s ON s.id = r.snippet_id
+	JOIN users u ON u.id = r.user_id
+	WHERE ` + liveSnippet + ` AND r.snippet_id = $1
+	ORDER BY r.version DESC`
+
+	rows, err := self.DB.Query(context.Background(), stmt, id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	revisions := []*Revision{}
+
+	for rows.Next() {
+		r := &Revision{}
+
+		err = rows.Scan(&r.ID, &r.SnippetID, &r.Version, &r.UserID, &r.Author, &r.Title, &r.Content, &r.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, r)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
@@ -0,0 +1,163 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"snippetbox.davc.io/internal/query"
+)
+
+// Markers that Search puts around matching words in a headline. They are
+// control characters so that they can't clash with snippet content, and
+// must be replaced after the headline has been HTML-escaped.
+const (
+	HighlightStart = "\x02"
+	HighlightStop  = "\x03"
+)
+
+// Options passed to ts_headline for search result fragments.
+const headlineOptions = "StartSel=" + HighlightStart + ", StopSel=" + HighlightStop +
+	", MaxFragments=2, MaxWords=20, MinWords=5, FragmentDelimiter=\" … \""
+
+type SearchResult struct {
+	Snippet *Snippet
+	// Fragments of the content around the matching words.
+	Headline string
+}
+
+// One page of search results, best matches first. Page numbers start at 1.
+type SearchResults struct {
+	Results []*SearchResult
+	Page    int
+	More    bool
+}
+
+// Search snippet titles and content. Words and phrases must all match, and
+// filters narrow the results further.
+func (self *SnippetModel) Search(q *query.Query, page int, size int) (*SearchResults, error) {
+	size = max(min(size, MaxPageSize), 1)
+	page = max(page, 1)
+
+	where, textQuery, args, err := compileQuery(q)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(args)
+	stmt := fmt.Sprintf(`SELECT %s, ts_headline('english', s.content, t.q, $%d)
+	FROM snippets s
+	JOIN users u ON u.id = s.user_id
+	CROSS JOIN (SELECT %s AS q) t
+	WHERE %s
+	ORDER BY ts_rank(s.search, t.q) DESC, s.created DESC, s.id DESC
+	LIMIT $%d OFFSET $%d`, snippetColumns, n+1, textQuery, where, n+2, n+3)
+
+	// Ask for one extra row to find out whether there's a further page.
+	args = append(args, headlineOptions, size+1, (page-1)*size)
+
+	rows, err := self.DB.Query(context.Background(), stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	results := &SearchResults{Results: []*SearchResult{}, Page: page}
+
+	for rows.Next() {
+		r := &SearchResult{Snippet: &Snippet{}}
+
+		err = scanSnippet(rows, r.Snippet, &r.Headline)
+		if err != nil {
+			return nil, err
+		}
+
+		results.Results = append(results.Results, r)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(results.Results) > size {
+		results.Results = results.Results[:size]
+		results.More = true
+	}
+
+	return results, nil
+}
+
+// Translate a parsed query into a condition on the aliased snippets (s) and
+// users (u) tables, using args as its placeholders. textQuery is a tsquery
+// expression matching the wanted words and phrases, used for ranking and
+// headlines.
+func compileQuery(q *query.Query) (where string, textQuery string, args []any, err error) {
+	// Protected snippets are left out, as matching their content would
+	// reveal what it says, as view-limited ones are from every listing.
+	conditions := []string{listedSnippet, "s.hashed_password IS NULL"}
+	textParts := []string{}
+
+	// Add a value to args and return its placeholder.
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	for _, t := range q.Terms {
+		var cond string
+
+		switch t.Kind {
+		case query.Word, query.Phrase:
+			fn := "plainto_tsquery"
+			if t.Kind == query.Phrase {
+				fn = "phraseto_tsquery"
+			}
+
+			tsquery := fmt.Sprintf("%s('english', %s)", fn, arg(t.Value))
+			if !t.Negated {
+				textParts = append(textParts, tsquery)
+				continue
+			}
+			cond = "s.search @@ " + tsquery
+		case query.Tag:
+			cond = hasTag(arg(strings.ToLower(t.Value)))
+		case query.Lang:
+			cond = "s.language = " + arg(strings.ToLower(t.Value))
+		case query.Author:
+			cond = "u.name ILIKE " + arg(escapeLike(t.Value)+"%")
+		case query.Before:
+			cond = "s.created < " + arg(t.Date)
+		case query.After:
+			cond = "s.created >= " + arg(t.Date)
+		case query.Expires:
+			op := "<="
+			if t.Later {
+				op = ">"
+			}
+			// Snippets that never expire only match "later".
+			cond = fmt.Sprintf("coalesce(s.expires, 'infinity') %s now() + make_interval(secs => %s)", op, arg(t.Within.Seconds()))
+		default:
+			return "", "", nil, fmt.Errorf("%w: %s", ErrUnsupportedFilter, t.Kind)
+		}
+
+		if t.Negated {
+			cond = "NOT (" + cond + ")"
+		}
+		conditions = append(conditions, cond)
+	}
+
+	textQuery = "''::tsquery"
+	if len(textParts) > 0 {
+		textQuery = strings.Join(textParts, " && ")
+		conditions = append(conditions, "s.search @@ t.q")
+	}
+
+	return strings.Join(conditions, " AND "), textQuery, args, nil
+}
+
+// Escape the wildcard characters of a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
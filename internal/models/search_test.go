@@ -0,0 +1,64 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"snippetbox.davc.io/internal/assert"
+	"snippetbox.davc.io/internal/query"
+)
+
+func TestCompileQuery(t *testing.T) {
+	q, err := query.Parse(`pond "silent pond" -frog author:100%_al before:2024-01-01 expires:>1d`)
+	assert.NilError(t, err)
+
+	where, textQuery, args, err := compileQuery(q)
+	assert.NilError(t, err)
+
+	assert.Equal(t, where, liveSnippet+" AND s.visibility = 'public' AND s.views_left IS NULL AND s.hashed_password IS NULL"+
+		" AND NOT (s.search @@ plainto_tsquery('english', $3))"+
+		" AND u.name ILIKE $4"+
+		" AND s.created < $5"+
+		" AND coalesce(s.expires, 'infinity') > now() + make_interval(secs => $6)"+
+		" AND s.search @@ t.q")
+	assert.Equal(t, textQuery, "plainto_tsquery('english', $1) && phraseto_tsquery('english', $2)")
+
+	wantArgs := []any{"pond", "silent pond", "frog", `100\%\_al%`, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), float64(86400)}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("got: %v; want: %v", args, wantArgs)
+	}
+}
+
+func TestCompileQueryFiltersOnly(t *testing.T) {
+	q, err := query.Parse("-author:bob")
+	assert.NilError(t, err)
+
+	where, textQuery, _, err := compileQuery(q)
+	assert.NilError(t, err)
+
+	assert.Equal(t, where, liveSnippet+" AND s.visibility = 'public' AND s.views_left IS NULL AND s.hashed_password IS NULL AND NOT (u.name ILIKE $1)")
+	assert.Equal(t, textQuery, "''::tsquery")
+}
+
+func TestCompileQueryLang(t *testing.T) {
+	q, err := query.Parse("-lang:SQL")
+	assert.NilError(t, err)
+
+	where, _, args, err := compileQuery(q)
+	assert.NilError(t, err)
+
+	assert.StringContains(t, where, "NOT (s.language = $1)")
+	assert.Equal(t, args[0], any("sql"))
+}
+
+func TestCompileQueryTag(t *testing.T) {
+	q, err := query.Parse("tag:Go")
+	assert.NilError(t, err)
+
+	where, _, args, err := compileQuery(q)
+	assert.NilError(t, err)
+
+	assert.StringContains(t, where, "t.name = $1)")
+	assert.Equal(t, args[0], any("go"))
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"crypto/rand"
+	"math/big"
+	"strings"
+)
+
+// Length of a snippet slug. Ten base62 characters give about 59 bits of
+// randomness, which is plenty to make slugs unguessable.
+const SlugLength = 10
+
+const slugAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// How many times Insert draws a new slug after a collision before giving up.
+const maxSlugAttempts = 5
+
+// Generate a random slug. Slugs always contain a letter so that they can't
+// be mistaken for numeric snippet IDs.
+func newSlug() (string, error) {
+	b := make([]byte, SlugLength)
+	n := big.NewInt(int64(len(slugAlphabet)))
+
+	for {
+		for i := range b {
+			c, err := rand.Int(rand.Reader, n)
+			if err != nil {
+				return "", err
+			}
+			b[i] = slugAlphabet[c.Int64()]
+		}
+
+		slug := string(b)
+		if strings.Trim(slug, "0123456789") != "" {
+			return slug, nil
+		}
+	}
+}
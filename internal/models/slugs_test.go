@@ -0,0 +1,24 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"snippetbox.davc.io/internal/assert"
+)
+
+func TestNewSlug(t *testing.T) {
+	seen := map[string]bool{}
+
+	for i := 0; i < 1000; i++ {
+		slug, err := newSlug()
+		assert.NilError(t, err)
+
+		assert.Equal(t, len(slug), SlugLength)
+		assert.Equal(t, strings.Trim(slug, slugAlphabet), "")
+		assert.Equal(t, strings.Trim(slug, "0123456789") != "", true)
+		assert.Equal(t, seen[slug], false)
+
+		seen[slug] = true
+	}
+}
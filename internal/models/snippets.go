@@ -2,66 +2,554 @@ package models
 
 import (
 	"context"
-	"database/sql"
 	"errors"
+	"html/template"
 	"time"
 
+	"snippetbox.davc.io/internal/highlight"
+	"snippetbox.davc.io/internal/markdown"
+	"snippetbox.davc.io/internal/query"
+
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type SnippetModelInterface interface {
-	Insert(title string, content string, expires int) (int, error)
-	Get(id int) (*Snippet, error)
-	Latest() ([]*Snippet, error)
+	Insert(userID int, title string, filename string, content string, language string, files []*File, visibility Visibility, password string, maxViews int, expires Expiry, tags []string) (string, error)
+	Fork(id int, userID int) (string, error)
+	Forks(id int, limit int) ([]*Snippet, error)
+	Get(id int, viewerID int) (*Snippet, error)
+	GetBySlug(slug string, viewerID int) (*Snippet, error)
+	CheckPassword(id int, password string) error
+	ConsumeView(id int) (int, error)
+	Latest(cursor string, size int) (*SnippetPage, error)
+	ByOwner(userID int, cursor string, size int) (*SnippetPage, error)
+	ByTag(tag string, cursor string, size int) (*SnippetPage, error)
+	Update(id int, userID int, title string, filename string, content string, language string, files []*File, visibility Visibility, tags []string) error
+	Revisions(id int) ([]*Revision, error)
+	Delete(id int, userID int) error
+	Trash(userID int) ([]*Snippet, error)
+	Restore(id int, userID int) error
+	Purge(id int, userID int) error
+	Search(q *query.Query, page int, size int) (*SearchResults, error)
 }
 
+// Who can see a snippet.
+type Visibility string
+
+const (
+	// Listed on the home page, tag pages and in search results.
+	VisibilityPublic Visibility = "public"
+	// Only reachable by a direct link.
+	VisibilityUnlisted Visibility = "unlisted"
+	// Only visible to its owner.
+	VisibilityPrivate Visibility = "private"
+)
+
 type Snippet struct {
-	ID      int
-	Title   string
-	Content string
-	Created time.Time
-	Expires time.Time
+	ID int
+	// Random identifier used in URLs instead of the sequential ID.
+	Slug   string
+	UserID int
+	Author string
+	Title  string
+	// Name of the first file, which may be empty for a snippet with a
+	// single file.
+	Filename string
+	Content  string
+	// Language is a name from highlight.Languages, or one guessed by the
+	// highlighter.
+	Language string
+	// Content rendered when the snippet was saved: highlighted source, or a
+	// sanitized document for Markdown. Empty for snippets saved before
+	// rendering existed.
+	Highlighted template.HTML
+	Visibility  Visibility
+	// Set when viewers other than the owner need a password to see the
+	// snippet.
+	Protected bool
+	// How many more times the snippet may be viewed, or nil when there's no
+	// limit. Snippets are hidden once this reaches zero.
+	ViewsLeft *int
+	Created   time.Time
+	// Further files, only loaded when a single snippet is looked up.
+	Files []*File
+	// When the snippet expires, or nil when it never does.
+	Expires *time.Time
+	// ID of the snippet this one was forked from, or nil when it wasn't
+	// forked or its source has been deleted.
+	ForkedFrom *int
+	// Slug of the snippet this one was forked from, to link to it by, as
+	// unlisted snippets can't be opened by ID.
+	ForkedFromSlug *string
+	// How many forks of the snippet anyone may see.
+	Forks int
+	// How many users have starred the snippet.
+	Stars int
+	Tags  []string
+}
+
+// When a new snippet expires: a number of minutes after it's created, or a
+// fixed time. The zero value never expires.
+type Expiry struct {
+	Minutes int
+	At      time.Time
 }
 
 type SnippetModel struct {
 	DB *pgxpool.Pool
 }
 
-func (self *SnippetModel) Insert(title string, content string, expires int) (int, error) {
-	stmt := `INSERT INTO snippets (title, content, expires)
-	VALUES ($1, $2, now() + interval '$3 days') returning id`
+// Columns read by every snippet query, in the order scanSnippet expects them.
+const snippetColumns = `s.id, s.slug, s.user_id, u.name, s.title, s.filename, s.content, s.language, s.highlighted,
+	s.visibility, s.hashed_password IS NOT NULL, s.views_left, s.created, s.expires, s.forked_from,
+	(SELECT src.slug FROM snippets src WHERE src.id = s.forked_from),
+	(SELECT count(*) FROM snippets f WHERE f.forked_from = s.id AND f.visibility = 'public'
+		AND (f.expires IS NULL OR f.expires > now()) AND f.deleted_at IS NULL AND f.views_left IS NULL),
+	(SELECT count(*) FROM stars st WHERE st.snippet_id = s.id),
+	array(SELECT t.name FROM snippet_tags st JOIN tags t ON t.id = st.tag_id
+		WHERE st.snippet_id = s.id ORDER BY t.name)`
 
-	lastInsertId := 0
-	err := self.DB.QueryRow(context.Background(), stmt, title, content, expires).Scan(&lastInsertId)
+// Condition on the aliased snippets table matching snippets that can still
+// be seen: not expired, not in the trash and not out of views.
+const liveSnippet = `(s.expires IS NULL OR s.expires > now()) AND s.deleted_at IS NULL AND (s.views_left IS NULL OR s.views_left > 0)`
+
+// Condition matching live snippets that may be listed for anyone to find.
+// View-limited snippets never are, even when public, as every crawler or link
+// preview following a listing would use up a view.
+const listedSnippet = liveSnippet + ` AND s.visibility = 'public' AND s.views_left IS NULL`
+
+const snippetSelect = `SELECT ` + snippetColumns + `
+	FROM snippets s JOIN users u ON u.id = s.user_id`
+
+// Scan snippetColumns into s, followed by any extra columns the query
+// selected after them.
+func scanSnippet(row pgx.Row, s *Snippet, extra ...any) error {
+	dest := []any{&s.ID, &s.Slug, &s.UserID, &s.Author, &s.Title, &s.Filename, &s.Content, &s.Language, &s.Highlighted,
+		&s.Visibility, &s.Protected, &s.ViewsLeft, &s.Created, &s.Expires, &s.ForkedFrom, &s.ForkedFromSlug, &s.Forks, &s.Stars, &s.Tags}
+	return row.Scan(append(dest, extra...)...)
+}
+
+// Render content for display in the given language, which may be
+// highlight.Auto, with line anchors made of linePrefix and the line number.
+// It returns the HTML and the language that was used.
+func render(language string, content string, linePrefix string) (template.HTML, string, error) {
+	// Guess first, so that Markdown is rendered as a document either way.
+	if language == highlight.Auto {
+		language = highlight.Guess(content)
+	}
+
+	if language == highlight.Markdown {
+		html, err := markdown.Render(content)
+		return template.HTML(html), language, err
+	}
+
+	html, language, err := highlight.HighlightLines(language, content, linePrefix)
+	return template.HTML(html), language, err
+}
+
+// Insert a snippet together with its first revision and return its slug.
+// The filename, content and language are those of its first file, and files
+// any that follow. Languages may be highlight.Auto to have them guessed from
+// the content. Only the first file is kept in the snippet's revisions. An
+// empty password leaves the snippet unprotected, and a maxViews of 0 lets it
+// be viewed any number of times.
+func (self *SnippetModel) Insert(userID int, title string, filename string, content string, language string, files []*File, visibility Visibility, password string, maxViews int, expires Expiry, tags []string) (string, error) {
+	ctx := context.Background()
+
+	highlighted, language, err := render(language, content, highlight.LinePrefix)
 	if err != nil {
-		return 0, err
+		return "", err
+	}
+
+	var hashedPassword *string
+	if password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+		if err != nil {
+			return "", err
+		}
+		h := string(hash)
+		hashedPassword = &h
 	}
 
-	return int(lastInsertId), nil
+	var viewsLeft *int
+	if maxViews > 0 {
+		viewsLeft = &maxViews
+	}
+
+	// Both are left NULL for a snippet that never expires.
+	var expiresIn *int
+	var expiresAt *time.Time
+	switch {
+	case !expires.At.IsZero():
+		expiresAt = &expires.At
+	case expires.Minutes > 0:
+		expiresIn = &expires.Minutes
+	}
+
+	tx, err := self.DB.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// Rollback is a no-op once the transaction has been committed.
+	defer tx.Rollback(ctx)
+
+	stmt := `INSERT INTO snippets (slug, user_id, title, filename, content, language, highlighted, visibility,
+		hashed_password, views_left, expires)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10,
+		coalesce($12::timestamptz, now() + make_interval(mins => $11::integer)))
+	ON CONFLICT (slug) DO NOTHING returning id`
+
+	lastInsertId, slug, err := insertWithSlug(ctx, tx, stmt, userID, title, filename, content, language, highlighted, visibility,
+		hashedPassword, viewsLeft, expiresIn, expiresAt)
+	if err != nil {
+		return "", err
+	}
+
+	err = insertRevision(ctx, tx, lastInsertId, userID, title, content)
+	if err != nil {
+		return "", err
+	}
+
+	err = setFiles(ctx, tx, lastInsertId, files)
+	if err != nil {
+		return "", err
+	}
+
+	err = setTags(ctx, tx, lastInsertId, tags)
+	if err != nil {
+		return "", err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return slug, nil
+}
+
+// Run stmt, an insert into snippets or collections with ON CONFLICT (slug)
+// DO NOTHING that returns the new id, with a fresh slug as $1 followed by
+// args. A slug collision inserts nothing rather than failing, which would
+// abort the transaction, so another slug can be tried.
+func insertWithSlug(ctx context.Context, tx pgx.Tx, stmt string, args ...any) (int, string, error) {
+	for attempt := 1; ; attempt++ {
+		slug, err := newSlug()
+		if err != nil {
+			return 0, "", err
+		}
+
+		id := 0
+		err = tx.QueryRow(ctx, stmt, append([]any{slug}, args...)...).Scan(&id)
+		if err == nil {
+			return id, slug, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return 0, "", err
+		}
+		if attempt == maxSlugAttempts {
+			return 0, "", ErrSlugCollision
+		}
+	}
 }
 
-func (self *SnippetModel) Get(id int) (*Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-	WHERE expires > now() AND id = $1`
+// Copy a live snippet that userID may see into a new snippet owned by userID
+// and return the new snippet's slug. The fork keeps the source's files,
+// visibility, tags and expiry, but not its password or view limit, and starts
+// its own history.
+func (self *SnippetModel) Fork(id int, userID int) (string, error) {
+	ctx := context.Background()
+
+	tx, err := self.DB.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	defer tx.Rollback(ctx)
+
+	var title, content string
+
+	stmt := `SELECT s.title, s.content FROM snippets s
+	WHERE ` + liveSnippet + ` AND s.id = $1
+	AND (s.visibility <> 'private' OR s.user_id = $2)
+	FOR SHARE`
+
+	err = tx.QueryRow(ctx, stmt, id, userID).Scan(&title, &content)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrNoRecord
+		}
+		return "", err
+	}
+
+	stmt = `INSERT INTO snippets (slug, user_id, title, filename, content, language, highlighted, visibility,
+		expires, forked_from)
+	SELECT $1, $2, title, filename, content, language, highlighted, visibility, expires, id
+	FROM snippets WHERE id = $3
+	ON CONFLICT (slug) DO NOTHING returning id`
+
+	forkID, slug, err := insertWithSlug(ctx, tx, stmt, userID, id)
+	if err != nil {
+		return "", err
+	}
+
+	err = insertRevision(ctx, tx, forkID, userID, title, content)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec(ctx, `INSERT INTO snippet_files (snippet_id, position, filename, language, content, highlighted)
+	SELECT $1, position, filename, language, content, highlighted FROM snippet_files WHERE snippet_id = $2`, forkID, id)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec(ctx, `INSERT INTO snippet_tags (snippet_id, tag_id)
+	SELECT $1, tag_id FROM snippet_tags WHERE snippet_id = $2`, forkID, id)
+	if err != nil {
+		return "", err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return slug, nil
+}
+
+// The newest public forks of a snippet, at most limit of them.
+func (self *SnippetModel) Forks(id int, limit int) ([]*Snippet, error) {
+	stmt := snippetSelect + `
+	WHERE ` + listedSnippet + ` AND s.forked_from = $1
+	ORDER BY s.created DESC, s.id DESC
+	LIMIT $2`
+
+	return self.query(stmt, id, limit)
+}
+
+// Update the title, files, visibility and tags of a snippet owned by userID,
+// rendering it again and keeping the new version of its first file as a
+// revision.
+func (self *SnippetModel) Update(id int, userID int, title string, filename string, content string, language string, files []*File, visibility Visibility, tags []string) error {
+	ctx := context.Background()
+
+	highlighted, language, err := render(language, content, highlight.LinePrefix)
+	if err != nil {
+		return err
+	}
+
+	tx, err := self.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	stmt := `UPDATE snippets SET title = $1, filename = $2, content = $3, language = $4, highlighted = $5, visibility = $6
+	WHERE id = $7 AND user_id = $8 AND (expires IS NULL OR expires > now()) AND deleted_at IS NULL`
+
+	tag, err := tx.Exec(ctx, stmt, title, filename, content, language, highlighted, visibility, id, userID)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRecord
+	}
+
+	err = insertRevision(ctx, tx, id, userID, title, content)
+	if err != nil {
+		return err
+	}
+
+	err = setFiles(ctx, tx, id, files)
+	if err != nil {
+		return err
+	}
+
+	err = setTags(ctx, tx, id, tags)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Get a live snippet. Private snippets are only found when viewerID is their
+// owner; pass 0 for anonymous viewers.
+func (self *SnippetModel) Get(id int, viewerID int) (*Snippet, error) {
+	stmt := snippetSelect + `
+	WHERE ` + liveSnippet + ` AND s.id = $1
+	AND (s.visibility <> 'private' OR s.user_id = $2)`
 
 	s := &Snippet{}
 
-	err := self.DB.QueryRow(context.Background(), stmt, id).Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	err := scanSnippet(self.DB.QueryRow(context.Background(), stmt, id, viewerID), s)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
+		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, ErrNoRecord
 		}
 		return nil, err
 	}
 
+	s.Files, err = self.files(s.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Get a live snippet by its slug, with the same visibility rules as Get.
+func (self *SnippetModel) GetBySlug(slug string, viewerID int) (*Snippet, error) {
+	stmt := snippetSelect + `
+	WHERE ` + liveSnippet + ` AND s.slug = $1
+	AND (s.visibility <> 'private' OR s.user_id = $2)`
+
+	s := &Snippet{}
+
+	err := scanSnippet(self.DB.QueryRow(context.Background(), stmt, slug, viewerID), s)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	s.Files, err = self.files(s.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	return s, nil
 }
 
-func (self *SnippetModel) Latest() ([]*Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-	WHERE expires > now() ORDER BY id DESC LIMIT 10`
+// Check the password of a protected snippet. It returns ErrInvalidCredentials
+// when the password is wrong and ErrNoRecord when the snippet doesn't exist
+// or isn't protected.
+func (self *SnippetModel) CheckPassword(id int, password string) error {
+	var hashedPassword []byte
+
+	stmt := `SELECT hashed_password FROM snippets
+	WHERE id = $1 AND hashed_password IS NOT NULL AND (expires IS NULL OR expires > now()) AND deleted_at IS NULL`
+
+	err := self.DB.QueryRow(context.Background(), stmt, id).Scan(&hashedPassword)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrInvalidCredentials
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Count a view of a snippet with limited views and return how many views
+// are left. The check and the decrement are one statement, so when several
+// requests race for the last view only one of them gets it; the others, like
+// any view of a snippet that is out of views, get ErrNoRecord.
+func (self *SnippetModel) ConsumeView(id int) (int, error) {
+	var viewsLeft int
+
+	stmt := `UPDATE snippets SET views_left = views_left - 1
+	WHERE id = $1 AND views_left > 0 AND (expires IS NULL OR expires > now()) AND deleted_at IS NULL
+	RETURNING views_left`
+
+	err := self.DB.QueryRow(context.Background(), stmt, id).Scan(&viewsLeft)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+		return 0, err
+	}
+
+	return viewsLeft, nil
+}
+
+// Public snippets, newest first.
+func (self *SnippetModel) Latest(cursor string, size int) (*SnippetPage, error) {
+	where := listedSnippet
+
+	return self.page(where, nil, cursor, size)
+}
+
+// Snippets created by a user, whatever their visibility, newest first.
+func (self *SnippetModel) ByOwner(userID int, cursor string, size int) (*SnippetPage, error) {
+	where := liveSnippet + " AND s.user_id = $1"
+
+	return self.page(where, []any{userID}, cursor, size)
+}
+
+// Public snippets carrying a tag, newest first.
+func (self *SnippetModel) ByTag(tag string, cursor string, size int) (*SnippetPage, error) {
+	where := listedSnippet + " AND " + hasTag("$1")
+
+	return self.page(where, []any{tag}, cursor, size)
+}
+
+// Move a snippet owned by userID to the trash.
+func (self *SnippetModel) Delete(id int, userID int) error {
+	stmt := `UPDATE snippets SET deleted_at = now()
+	WHERE id = $1 AND user_id = $2 AND (expires IS NULL OR expires > now()) AND deleted_at IS NULL`
+
+	return self.exec(stmt, id, userID)
+}
+
+// Snippets in a user's trash, most recently deleted first.
+func (self *SnippetModel) Trash(userID int) ([]*Snippet, error) {
+	stmt := snippetSelect + `
+	WHERE (s.expires IS NULL OR s.expires > now()) AND s.deleted_at IS NOT NULL AND s.user_id = $1
+	ORDER BY s.deleted_at DESC`
+
+	return self.query(stmt, userID)
+}
+
+// Take a snippet owned by userID back out of the trash.
+func (self *SnippetModel) Restore(id int, userID int) error {
+	stmt := `UPDATE snippets SET deleted_at = NULL
+	WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL`
+
+	return self.exec(stmt, id, userID)
+}
+
+// Permanently delete a trashed snippet owned by userID, along with its
+// revisions.
+func (self *SnippetModel) Purge(id int, userID int) error {
+	stmt := `DELETE FROM snippets
+	WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL`
+
+	return self.exec(stmt, id, userID)
+}
+
+// Run a statement that must affect a single snippet, returning ErrNoRecord
+// when it matched none.
+func (self *SnippetModel) exec(stmt string, args ...any) error {
+	tag, err := self.DB.Exec(context.Background(), stmt, args...)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
 
-	rows, err := self.DB.Query(context.Background(), stmt)
+func (self *SnippetModel) query(stmt string, args ...any) ([]*Snippet, error) {
+	rows, err := self.DB.Query(context.Background(), stmt, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -73,7 +561,7 @@ func (self *SnippetModel) Latest() ([]*Snippet, error) {
 	for rows.Next() {
 		s := &Snippet{}
 
-		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		err = scanSnippet(rows, s)
 		if err != nil {
 			return nil, err
 		}
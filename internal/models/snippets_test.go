@@ -0,0 +1,249 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"snippetbox.davc.io/internal/assert"
+	"snippetbox.davc.io/internal/highlight"
+	"snippetbox.davc.io/internal/query"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name         string
+		language     string
+		content      string
+		wantLanguage string
+		wantHTML     string
+	}{
+		{
+			name:         "Source",
+			language:     "sql",
+			content:      "SELECT 1;",
+			wantLanguage: "sql",
+			wantHTML:     `<span class="k">SELECT</span>`,
+		},
+		{
+			name:         "Markdown",
+			language:     highlight.Markdown,
+			content:      "*pond*",
+			wantLanguage: highlight.Markdown,
+			wantHTML:     "<p><em>pond</em></p>",
+		},
+		{
+			name:         "Guessed Markdown",
+			language:     highlight.Auto,
+			content:      "# Pond\n\n- old\n- silent\n",
+			wantLanguage: highlight.Markdown,
+			wantHTML:     "<h1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html, language, err := render(tt.language, tt.content, highlight.LinePrefix)
+
+			assert.NilError(t, err)
+			assert.Equal(t, language, tt.wantLanguage)
+			assert.StringContains(t, string(html), tt.wantHTML)
+		})
+	}
+}
+
+func TestSnippetModelInsertExpiry(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	at := time.Date(2099, time.March, 4, 5, 6, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		expires Expiry
+		// How long after now the snippet should expire, give or take a
+		// minute, or zero to compare with wantAt.
+		wantIn time.Duration
+		wantAt *time.Time
+	}{
+		{
+			name:    "Minutes",
+			expires: Expiry{Minutes: 90},
+			wantIn:  90 * time.Minute,
+		},
+		{
+			name:    "Weeks",
+			expires: Expiry{Minutes: 2 * 7 * 24 * 60},
+			wantIn:  2 * 7 * 24 * time.Hour,
+		},
+		{
+			name:    "Fixed time",
+			expires: Expiry{At: at},
+			wantAt:  &at,
+		},
+		{
+			name:    "Never",
+			expires: Expiry{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+
+			m := SnippetModel{db}
+
+			slug, err := m.Insert(1, "Title", "", "Content", highlight.PlainText, nil, VisibilityPublic, "", 0, tt.expires, nil)
+			assert.NilError(t, err)
+
+			snippet, err := m.GetBySlug(slug, 0)
+			assert.NilError(t, err)
+
+			switch {
+			case tt.wantIn != 0:
+				if snippet.Expires == nil {
+					t.Fatal("got: nil; want: an expiry time")
+				}
+				diff := time.Until(*snippet.Expires) - tt.wantIn
+				if diff < -time.Minute || diff > time.Minute {
+					t.Errorf("got: expires in %v; want: %v", time.Until(*snippet.Expires), tt.wantIn)
+				}
+			case tt.wantAt != nil:
+				if snippet.Expires == nil {
+					t.Fatal("got: nil; want: an expiry time")
+				}
+				assert.Equal(t, snippet.Expires.Equal(*tt.wantAt), true)
+			default:
+				assert.Equal(t, snippet.Expires, nil)
+			}
+		})
+	}
+
+	t.Run("Expired", func(t *testing.T) {
+		db := newTestDB(t)
+
+		m := SnippetModel{db}
+
+		slug, err := m.Insert(1, "Title", "", "Content", highlight.PlainText, nil, VisibilityPublic, "", 0,
+			Expiry{At: time.Now().Add(-time.Hour)}, nil)
+		assert.NilError(t, err)
+
+		_, err = m.GetBySlug(slug, 0)
+		assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+	})
+}
+
+func TestSnippetModelFork(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := SnippetModel{db}
+
+	slug, err := m.Insert(1, "Title", "", "Content", highlight.PlainText, nil, VisibilityPublic, "secret password", 0, Expiry{}, []string{"go"})
+	assert.NilError(t, err)
+
+	source, err := m.GetBySlug(slug, 0)
+	assert.NilError(t, err)
+
+	forkSlug, err := m.Fork(source.ID, 1)
+	assert.NilError(t, err)
+
+	fork, err := m.GetBySlug(forkSlug, 0)
+	assert.NilError(t, err)
+
+	assert.Equal(t, fork.Title, "Title")
+	assert.Equal(t, fork.Content, "Content")
+	assert.Equal(t, fork.Protected, false)
+	assert.Equal(t, *fork.ForkedFrom, source.ID)
+	assert.Equal(t, *fork.ForkedFromSlug, slug)
+	assert.Equal(t, len(fork.Tags), 1)
+
+	revisions, err := m.Revisions(fork.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, len(revisions), 1)
+
+	source, err = m.GetBySlug(slug, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, source.Forks, 1)
+
+	forks, err := m.Forks(source.ID, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(forks), 1)
+
+	_, err = m.Fork(source.ID+100, 1)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+}
+
+func TestSnippetModelListed(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := SnippetModel{db}
+
+	slug, err := m.Insert(1, "Burn after reading", "", "Unlisted content", highlight.PlainText, nil, VisibilityPublic, "", 1, Expiry{}, []string{"secret"})
+	assert.NilError(t, err)
+
+	latest, err := m.Latest("", 100)
+	assert.NilError(t, err)
+	for _, s := range latest.Snippets {
+		assert.Equal(t, s.Slug == slug, false)
+	}
+
+	tagged, err := m.ByTag("secret", "", 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(tagged.Snippets), 0)
+
+	q, err := query.Parse("unlisted")
+	assert.NilError(t, err)
+
+	results, err := m.Search(q, 1, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(results.Results), 0)
+
+	// Its link still works, until the view is used up.
+	snippet, err := m.GetBySlug(slug, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, *snippet.ViewsLeft, 1)
+}
+
+func TestSnippetModelFiles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := SnippetModel{db}
+
+	files := []*File{
+		{Filename: "go.mod", Language: highlight.PlainText, Content: "module hello"},
+		{Filename: "README.md", Language: highlight.Markdown, Content: "# Hello"},
+	}
+
+	slug, err := m.Insert(1, "Hello module", "main.go", "package main", "go", files, VisibilityPublic, "", 0, Expiry{}, nil)
+	assert.NilError(t, err)
+
+	snippet, err := m.GetBySlug(slug, 0)
+	assert.NilError(t, err)
+
+	assert.Equal(t, snippet.Filename, "main.go")
+	assert.Equal(t, len(snippet.Files), 2)
+	assert.Equal(t, snippet.Files[0].Position, 2)
+	assert.Equal(t, snippet.Files[0].Content, "module hello")
+	assert.StringContains(t, string(snippet.Files[0].Highlighted), `id="file-2-L1"`)
+	assert.Equal(t, snippet.Files[1].Filename, "README.md")
+
+	err = m.Update(snippet.ID, 1, "Hello module", "main.go", "package main", "go", files[:1], VisibilityPublic, nil)
+	assert.NilError(t, err)
+
+	snippet, err = m.GetBySlug(slug, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippet.Files), 1)
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type StarModelInterface interface {
+	Star(userID int, snippetID int) error
+	Unstar(userID int, snippetID int) error
+	Starred(userID int, snippetID int) (bool, error)
+	ByUser(userID int, cursor string, size int) (*SnippetPage, error)
+}
+
+type StarModel struct {
+	DB *pgxpool.Pool
+}
+
+// Star a snippet for a user. Starring a snippet twice does nothing.
+func (self *StarModel) Star(userID int, snippetID int) error {
+	stmt := `INSERT INTO stars (user_id, snippet_id) VALUES ($1, $2)
+	ON CONFLICT (user_id, snippet_id) DO NOTHING`
+
+	_, err := self.DB.Exec(context.Background(), stmt, userID, snippetID)
+	return err
+}
+
+// Remove a user's star from a snippet, if there is one.
+func (self *StarModel) Unstar(userID int, snippetID int) error {
+	stmt := `DELETE FROM stars WHERE user_id = $1 AND snippet_id = $2`
+
+	_, err := self.DB.Exec(context.Background(), stmt, userID, snippetID)
+	return err
+}
+
+// Report whether a user has starred a snippet.
+func (self *StarModel) Starred(userID int, snippetID int) (bool, error) {
+	var starred bool
+
+	stmt := `SELECT EXISTS(SELECT 1 FROM stars WHERE user_id = $1 AND snippet_id = $2)`
+
+	err := self.DB.QueryRow(context.Background(), stmt, userID, snippetID).Scan(&starred)
+	return starred, err
+}
+
+// Live snippets a user has starred, newest first. Snippets that have since
+// been made private by their owners are left out.
+func (self *StarModel) ByUser(userID int, cursor string, size int) (*SnippetPage, error) {
+	where := liveSnippet + ` AND (s.visibility <> 'private' OR s.user_id = $1)
+	AND EXISTS (SELECT 1 FROM stars st WHERE st.snippet_id = s.id AND st.user_id = $1)`
+
+	snippets := &SnippetModel{DB: self.DB}
+
+	return snippets.page(where, []any{userID}, cursor, size)
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"testing"
+
+	"snippetbox.davc.io/internal/assert"
+)
+
+func TestStarModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	snippets := SnippetModel{db}
+	m := StarModel{db}
+
+	slug, err := snippets.Insert(1, "Hello", "", "Hello, world", "plaintext", nil, VisibilityPublic, "", 0, Expiry{}, nil)
+	assert.NilError(t, err)
+
+	snippet, err := snippets.GetBySlug(slug, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Stars, 0)
+
+	// Starring twice counts once.
+	assert.NilError(t, m.Star(1, snippet.ID))
+	assert.NilError(t, m.Star(1, snippet.ID))
+
+	starred, err := m.Starred(1, snippet.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, starred, true)
+
+	snippet, err = snippets.GetBySlug(slug, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Stars, 1)
+
+	page, err := m.ByUser(1, "", 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(page.Snippets), 1)
+	assert.Equal(t, page.Snippets[0].Slug, slug)
+
+	assert.NilError(t, m.Unstar(1, snippet.ID))
+
+	starred, err = m.Starred(1, snippet.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, starred, false)
+
+	page, err = m.ByUser(1, "", 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(page.Snippets), 0)
+}
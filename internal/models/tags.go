@@ -0,0 +1,142 @@
+package models
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type TagModelInterface interface {
+	Suggest(prefix string, limit int) ([]string, error)
+	Cloud(limit int) ([]*TagCount, error)
+}
+
+// How many live snippets carry a tag. Weight ranks the count against the
+// most used tag of the same cloud, from 1 to 5.
+type TagCount struct {
+	Name   string
+	Count  int
+	Weight int
+}
+
+type TagModel struct {
+	DB *pgxpool.Pool
+}
+
+// Condition matching snippets (aliased s) that carry the tag named by the
+// placeholder.
+func hasTag(placeholder string) string {
+	return `EXISTS (SELECT 1 FROM snippet_tags st JOIN tags t ON t.id = st.tag_id
+		WHERE st.snippet_id = s.id AND t.name = ` + placeholder + `)`
+}
+
+// Replace the tags of a snippet, creating any tags that don't exist yet.
+func setTags(ctx context.Context, tx pgx.Tx, snippetID int, tags []string) error {
+	_, err := tx.Exec(ctx, "DELETE FROM snippet_tags WHERE snippet_id = $1", snippetID)
+	if err != nil {
+		return err
+	}
+
+	if len(tags) == 0 {
+		return nil
+	}
+
+	stmt := `INSERT INTO tags (name) SELECT unnest($1::text[]) ON CONFLICT (name) DO NOTHING`
+
+	_, err = tx.Exec(ctx, stmt, tags)
+	if err != nil {
+		return err
+	}
+
+	stmt = `INSERT INTO snippet_tags (snippet_id, tag_id)
+	SELECT $1, id FROM tags WHERE name = ANY($2)`
+
+	_, err = tx.Exec(ctx, stmt, snippetID, tags)
+	return err
+}
+
+// Names of tags on public snippets starting with prefix, most used first.
+func (self *TagModel) Suggest(prefix string, limit int) ([]string, error) {
+	stmt := `SELECT t.name FROM tags t
+	JOIN snippet_tags st ON st.tag_id = t.id
+	JOIN snippets s ON s.id = st.snippet_id
+	WHERE t.name LIKE $1
+	AND ` + listedSnippet + `
+	GROUP BY t.id
+	ORDER BY count(*) DESC, t.name
+	LIMIT $2`
+
+	rows, err := self.DB.Query(context.Background(), stmt, escapeLike(prefix)+"%", limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	names := []string{}
+
+	for rows.Next() {
+		var name string
+
+		err = rows.Scan(&name)
+		if err != nil {
+			return nil, err
+		}
+
+		names = append(names, name)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
+// The most used tags across live public snippets, in alphabetical order.
+func (self *TagModel) Cloud(limit int) ([]*TagCount, error) {
+	stmt := `SELECT name, count FROM (
+		SELECT t.name, count(*) AS count FROM tags t
+		JOIN snippet_tags st ON st.tag_id = t.id
+		JOIN snippets s ON s.id = st.snippet_id
+		WHERE ` + listedSnippet + `
+		GROUP BY t.id
+		ORDER BY count DESC, t.name
+		LIMIT $1
+	) top ORDER BY name`
+
+	rows, err := self.DB.Query(context.Background(), stmt, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tags := []*TagCount{}
+	most := 0
+
+	for rows.Next() {
+		t := &TagCount{}
+
+		err = rows.Scan(&t.Name, &t.Count)
+		if err != nil {
+			return nil, err
+		}
+
+		most = max(most, t.Count)
+		tags = append(tags, t)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range tags {
+		t.Weight = 1 + 4*(t.Count-1)/max(most-1, 1)
+	}
+
+	return tags, nil
+}
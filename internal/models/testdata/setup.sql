@@ -1,26 +1,144 @@
+create table users (
+    id serial not null primary key,
+    name varchar(255) not null,
+    email varchar(255) not null,
+    hashed_password char(60) not null,
+    created timestamptz default (now() at time zone 'utc')
+);
+
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
+
 create table snippets (
     id serial not null primary key,
+    slug varchar(10) not null unique,
+    user_id integer not null references users(id) on delete cascade,
     title varchar(100) not null,
+    filename varchar(100) not null default '',
     content text not null,
+    language varchar(30) not null default 'plaintext',
+    highlighted text not null default '',
+    visibility varchar(10) not null default 'public'
+        check (visibility in ('public', 'unlisted', 'private')),
+    hashed_password char(60),
+    views_left integer check (views_left >= 0),
     created timestamptz default (now() at time zone 'utc'),
-    expires timestamptz not null
+    expires timestamptz,
+    forked_from integer references snippets(id) on delete set null,
+    deleted_at timestamptz,
+    search tsvector generated always as (
+        setweight(to_tsvector('english', title), 'A') ||
+        setweight(to_tsvector('english', content), 'B')
+    ) stored
 );
 
-create index idx_snippets_created on snippets(created);
+create index idx_snippets_created on snippets(created, id);
+create index idx_snippets_user_id on snippets(user_id);
+create index idx_snippets_expires on snippets(expires);
+create index idx_snippets_forked_from on snippets(forked_from);
+create index idx_snippets_search on snippets using gin(search);
 
-create table users (
+create table snippet_revisions (
     id serial not null primary key,
-    name varchar(255) not null,
-    email varchar(255) not null,
-    hashed_password char(60) not null,
-    created timestamptz default (now() at time zone 'utc')
+    snippet_id integer not null references snippets(id) on delete cascade,
+    version integer not null,
+    user_id integer not null references users(id) on delete cascade,
+    title varchar(100) not null,
+    content text not null,
+    created timestamptz default (now() at time zone 'utc'),
+    unique (snippet_id, version)
 );
 
-ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
+create table snippet_files (
+    id serial not null primary key,
+    snippet_id integer not null references snippets(id) on delete cascade,
+    position integer not null,
+    filename varchar(100) not null,
+    language varchar(30) not null,
+    content text not null,
+    highlighted text not null,
+    unique (snippet_id, position),
+    unique (snippet_id, filename)
+);
+
+create table tags (
+    id serial not null primary key,
+    name varchar(30) not null unique
+);
+
+create table snippet_tags (
+    snippet_id integer not null references snippets(id) on delete cascade,
+    tag_id integer not null references tags(id) on delete cascade,
+    primary key (snippet_id, tag_id)
+);
+
+create index idx_snippet_tags_tag_id on snippet_tags(tag_id);
+
+create table snippet_views (
+    id bigserial not null primary key,
+    snippet_id integer not null references snippets(id) on delete cascade,
+    viewed timestamptz not null default now(),
+    referrer varchar(255) not null default '',
+    client char(16) not null
+);
+
+create index idx_snippet_views_snippet_id on snippet_views(snippet_id, viewed);
+
+create table stars (
+    user_id integer not null references users(id) on delete cascade,
+    snippet_id integer not null references snippets(id) on delete cascade,
+    created timestamptz not null default now(),
+    primary key (user_id, snippet_id)
+);
+
+create index idx_stars_snippet_id on stars(snippet_id);
+
+create table comments (
+    id serial not null primary key,
+    snippet_id integer not null references snippets(id) on delete cascade,
+    user_id integer not null references users(id) on delete cascade,
+    parent_id integer references comments(id) on delete cascade,
+    line varchar(20) not null default '',
+    body text not null,
+    rendered text not null,
+    created timestamptz not null default now(),
+    updated timestamptz,
+    deleted_at timestamptz
+);
+
+create index idx_comments_snippet_id on comments(snippet_id, created);
+create index idx_comments_parent_id on comments(parent_id);
+
+create table collections (
+    id serial not null primary key,
+    slug varchar(10) not null unique,
+    user_id integer not null references users(id) on delete cascade,
+    title varchar(100) not null,
+    description text not null default '',
+    visibility varchar(10) not null default 'public'
+        check (visibility in ('public', 'unlisted', 'private')),
+    created timestamptz not null default now()
+);
+
+create index idx_collections_user_id on collections(user_id);
+
+create table collection_snippets (
+    collection_id integer not null references collections(id) on delete cascade,
+    snippet_id integer not null references snippets(id) on delete cascade,
+    position integer not null,
+    primary key (collection_id, snippet_id)
+);
+
+create index idx_collection_snippets_snippet_id on collection_snippets(snippet_id);
+
+create table sessions (
+    token char(43) primary key,
+    data bytea not null,
+    expiry timestamptz(6) not null
+);
 
 INSERT INTO users (name, email, hashed_password, created) VALUES (
     'Alice Jones',
     'alice@example.com',
     '$2a$12$NuTjWXm3KKntReFwyBVHyuf/to.HEwTy.eS206TNfkGfr6HzGJSWG',
     '2022-01-01 10:00:00'
-);
+);
\ No newline at end of file
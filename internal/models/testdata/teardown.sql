@@ -1,3 +1,23 @@
-DROP TABLE users;
+DROP TABLE sessions;
 
-DROP TABLE snippets;
\ No newline at end of file
+DROP TABLE collection_snippets;
+
+DROP TABLE collections;
+
+DROP TABLE comments;
+
+DROP TABLE stars;
+
+DROP TABLE snippet_views;
+
+DROP TABLE snippet_tags;
+
+DROP TABLE tags;
+
+DROP TABLE snippet_files;
+
+DROP TABLE snippet_revisions;
+
+DROP TABLE snippets;
+
+DROP TABLE users;
\ No newline at end of file
@@ -6,6 +6,13 @@ import (
 	"unicode/utf8"
 )
 
+// Lowercase letters and digits, optionally separated by ".", "+" or "-".
+var TagRX = regexp.MustCompile("^[a-z0-9][a-z0-9.+-]*$")
+
+// Letters, digits, ".", "_" and "-", which are safe in URLs and on every
+// file system.
+var FilenameRX = regexp.MustCompile("^[A-Za-z0-9._-]+$")
+
 var EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 
 // Map of validation errors for form fields.
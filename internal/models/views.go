@@ -0,0 +1,147 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ViewModelInterface interface {
+	Insert(views []*View) error
+	Daily(snippetID int, days int) ([]*DailyViews, error)
+	Referrers(snippetID int, days int, limit int) ([]*ReferrerCount, error)
+}
+
+// A view of a snippet. Referrer is the host of the page that linked to it, or
+// empty when there was none. Client tells visitors apart on the day of the
+// view without saying who they are.
+type View struct {
+	SnippetID int
+	Viewed    time.Time
+	Referrer  string
+	Client    string
+}
+
+// How many times a snippet was viewed on a day (in UTC), and by how many
+// visitors.
+type DailyViews struct {
+	Day      time.Time
+	Views    int
+	Visitors int
+}
+
+// How many views of a snippet came from links on a host.
+type ReferrerCount struct {
+	Host  string
+	Views int
+}
+
+type ViewModel struct {
+	DB *pgxpool.Pool
+}
+
+// Save a batch of views. Views of snippets that have been deleted since are
+// dropped.
+func (self *ViewModel) Insert(views []*View) error {
+	if len(views) == 0 {
+		return nil
+	}
+
+	snippetIDs := make([]int, len(views))
+	viewed := make([]time.Time, len(views))
+	referrers := make([]string, len(views))
+	clients := make([]string, len(views))
+
+	for i, v := range views {
+		snippetIDs[i], viewed[i], referrers[i], clients[i] = v.SnippetID, v.Viewed, v.Referrer, v.Client
+	}
+
+	stmt := `INSERT INTO snippet_views (snippet_id, viewed, referrer, client)
+	SELECT v.snippet_id, v.viewed, v.referrer, v.client
+	FROM unnest($1::integer[], $2::timestamptz[], $3::text[], $4::text[]) AS v(snippet_id, viewed, referrer, client)
+	WHERE EXISTS (SELECT 1 FROM snippets s WHERE s.id = v.snippet_id)`
+
+	_, err := self.DB.Exec(context.Background(), stmt, snippetIDs, viewed, referrers, clients)
+	return err
+}
+
+// Views of a snippet on each of the last days days, up to and including
+// today, oldest first. Days without views are included with zero counts.
+func (self *ViewModel) Daily(snippetID int, days int) ([]*DailyViews, error) {
+	stmt := `SELECT d.day, count(v.id), count(DISTINCT v.client)
+	FROM generate_series(
+		((now() at time zone 'utc')::date - ($2::integer - 1))::timestamp,
+		(now() at time zone 'utc')::date::timestamp,
+		interval '1 day') AS d(day)
+	LEFT JOIN snippet_views v ON v.snippet_id = $1
+		AND v.viewed >= d.day at time zone 'utc'
+		AND v.viewed < (d.day + interval '1 day') at time zone 'utc'
+	GROUP BY d.day
+	ORDER BY d.day`
+
+	rows, err := self.DB.Query(context.Background(), stmt, snippetID, days)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	daily := []*DailyViews{}
+
+	for rows.Next() {
+		d := &DailyViews{}
+
+		err = rows.Scan(&d.Day, &d.Views, &d.Visitors)
+		if err != nil {
+			return nil, err
+		}
+
+		daily = append(daily, d)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return daily, nil
+}
+
+// Hosts that sent the most views to a snippet over the last days days, most
+// first.
+func (self *ViewModel) Referrers(snippetID int, days int, limit int) ([]*ReferrerCount, error) {
+	stmt := `SELECT referrer, count(*) FROM snippet_views
+	WHERE snippet_id = $1 AND referrer <> ''
+	AND viewed > now() - make_interval(days => $2)
+	GROUP BY referrer
+	ORDER BY count(*) DESC, referrer
+	LIMIT $3`
+
+	rows, err := self.DB.Query(context.Background(), stmt, snippetID, days, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	referrers := []*ReferrerCount{}
+
+	for rows.Next() {
+		r := &ReferrerCount{}
+
+		err = rows.Scan(&r.Host, &r.Views)
+		if err != nil {
+			return nil, err
+		}
+
+		referrers = append(referrers, r)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return referrers, nil
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"snippetbox.davc.io/internal/assert"
+)
+
+func TestViewModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	snippets := SnippetModel{db}
+	m := ViewModel{db}
+
+	slug, err := snippets.Insert(1, "Hello", "", "Hello, world", "plaintext", nil, VisibilityPublic, "", 0, Expiry{}, nil)
+	assert.NilError(t, err)
+
+	snippet, err := snippets.GetBySlug(slug, 0)
+	assert.NilError(t, err)
+
+	now := time.Now()
+
+	err = m.Insert([]*View{
+		{SnippetID: snippet.ID, Viewed: now, Referrer: "news.ycombinator.com", Client: "aaaaaaaaaaaaaaaa"},
+		{SnippetID: snippet.ID, Viewed: now, Referrer: "news.ycombinator.com", Client: "bbbbbbbbbbbbbbbb"},
+		{SnippetID: snippet.ID, Viewed: now, Referrer: "", Client: "aaaaaaaaaaaaaaaa"},
+		{SnippetID: snippet.ID, Viewed: now.AddDate(0, 0, -2), Referrer: "reddit.com", Client: "cccccccccccccccc"},
+		{SnippetID: snippet.ID, Viewed: now.AddDate(0, 0, -30), Referrer: "example.com", Client: "cccccccccccccccc"},
+		// Deleted snippets are skipped rather than failing the batch.
+		{SnippetID: 999, Viewed: now, Client: "dddddddddddddddd"},
+	})
+	assert.NilError(t, err)
+
+	daily, err := m.Daily(snippet.ID, 7)
+	assert.NilError(t, err)
+
+	assert.Equal(t, len(daily), 7)
+	assert.Equal(t, daily[6].Views, 3)
+	assert.Equal(t, daily[6].Visitors, 2)
+	assert.Equal(t, daily[5].Views, 0)
+	assert.Equal(t, daily[4].Views, 1)
+
+	referrers, err := m.Referrers(snippet.ID, 7, 10)
+	assert.NilError(t, err)
+
+	assert.Equal(t, len(referrers), 2)
+	assert.Equal(t, referrers[0].Host, "news.ycombinator.com")
+	assert.Equal(t, referrers[0].Views, 2)
+	assert.Equal(t, referrers[1].Host, "reddit.com")
+}
@@ -0,0 +1,344 @@
+// Package query parses the search box language:
+//
+//	tag:go author:alice lang:sql before:2024-01-01 expires:<7d "exact phrase" -exclude
+//
+// A query is a list of terms separated by whitespace. A term is a word, a
+// double-quoted phrase, or a filter written as key:value, where the value may
+// also be quoted. Any term can be negated with a leading "-".
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Most terms a query may contain.
+const MaxTerms = 20
+
+type Kind int
+
+const (
+	Word Kind = iota
+	Phrase
+	Tag
+	Author
+	Lang
+	Before
+	After
+	Expires
+)
+
+var kindNames = [...]string{
+	Word:    "word",
+	Phrase:  "phrase",
+	Tag:     "tag",
+	Author:  "author",
+	Lang:    "lang",
+	Before:  "before",
+	After:   "after",
+	Expires: "expires",
+}
+
+// Filter keys as written in a query.
+var filters = map[string]Kind{
+	"tag":     Tag,
+	"author":  Author,
+	"lang":    Lang,
+	"before":  Before,
+	"after":   After,
+	"expires": Expires,
+}
+
+func (k Kind) String() string {
+	return kindNames[k]
+}
+
+type Term struct {
+	Kind    Kind
+	Negated bool
+	// Text of a word, phrase, tag, author or lang term.
+	Value string
+	// Date of a before or after term, at midnight UTC.
+	Date time.Time
+	// An expires term matches snippets expiring within Within from now when
+	// Later is false, and those expiring after that when it's true.
+	Within time.Duration
+	Later  bool
+}
+
+type Query struct {
+	Terms []Term
+}
+
+// Returned by Parse for malformed queries. Pos is the byte offset of the
+// offending term.
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at character %d", e.Msg, e.Pos+1)
+}
+
+// Parse a query. An empty query has no terms.
+func Parse(s string) (*Query, error) {
+	p := &parser{s: s}
+	q := &Query{Terms: []Term{}}
+
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.s) {
+			return q, nil
+		}
+
+		if len(q.Terms) == MaxTerms {
+			return nil, p.errorf(p.pos, "too many terms (at most %d)", MaxTerms)
+		}
+
+		t, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+
+		q.Terms = append(q.Terms, t)
+	}
+}
+
+// String formats q so that parsing the result gives back an equal query.
+func (q *Query) String() string {
+	terms := make([]string, 0, len(q.Terms))
+
+	for _, t := range q.Terms {
+		var sb strings.Builder
+
+		if t.Negated {
+			sb.WriteByte('-')
+		}
+
+		switch t.Kind {
+		case Word:
+			sb.WriteString(t.Value)
+		case Phrase:
+			sb.WriteString(`"` + t.Value + `"`)
+		case Before, After:
+			sb.WriteString(t.Kind.String() + ":" + t.Date.Format(time.DateOnly))
+		case Expires:
+			op := "<"
+			if t.Later {
+				op = ">"
+			}
+			sb.WriteString("expires:" + op + formatDuration(t.Within))
+		default:
+			value := t.Value
+			if value[0] == '"' || strings.IndexFunc(value, unicode.IsSpace) >= 0 {
+				value = `"` + value + `"`
+			}
+			sb.WriteString(t.Kind.String() + ":" + value)
+		}
+
+		terms = append(terms, sb.String())
+	}
+
+	return strings.Join(terms, " ")
+}
+
+type parser struct {
+	s   string
+	pos int
+}
+
+func (p *parser) errorf(pos int, format string, args ...any) error {
+	return &SyntaxError{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.s) {
+		r, size := utf8.DecodeRuneInString(p.s[p.pos:])
+		if !unicode.IsSpace(r) {
+			return
+		}
+		p.pos += size
+	}
+}
+
+// Read up to the next whitespace character.
+func (p *parser) token() string {
+	start := p.pos
+	for p.pos < len(p.s) {
+		r, size := utf8.DecodeRuneInString(p.s[p.pos:])
+		if unicode.IsSpace(r) {
+			break
+		}
+		p.pos += size
+	}
+	return p.s[start:p.pos]
+}
+
+// Read a double-quoted string starting at the current position.
+func (p *parser) quoted() (string, error) {
+	start := p.pos
+
+	end := strings.IndexByte(p.s[start+1:], '"')
+	if end < 0 {
+		return "", p.errorf(start, "missing closing quote")
+	}
+
+	p.pos = start + 1 + end + 1
+	return p.s[start+1 : start+1+end], nil
+}
+
+func (p *parser) term() (Term, error) {
+	t := Term{}
+	start := p.pos
+
+	if p.s[p.pos] == '-' {
+		t.Negated = true
+		p.pos++
+
+		if r, _ := utf8.DecodeRuneInString(p.s[p.pos:]); p.pos >= len(p.s) || unicode.IsSpace(r) {
+			return t, p.errorf(start, "expected a term after \"-\"")
+		}
+		if p.s[p.pos] == '-' {
+			return t, p.errorf(p.pos, "unexpected \"-\"")
+		}
+	}
+
+	if p.s[p.pos] == '"' {
+		value, err := p.quoted()
+		if err != nil {
+			return t, err
+		}
+		if strings.TrimSpace(value) == "" {
+			return t, p.errorf(start, "empty phrase")
+		}
+
+		t.Kind = Phrase
+		t.Value = value
+		return t, nil
+	}
+
+	keyStart := p.pos
+	key, isFilter := p.filterKey()
+	if !isFilter {
+		t.Kind = Word
+		t.Value = p.token()
+		return t, nil
+	}
+
+	kind, ok := filters[strings.ToLower(key)]
+	if !ok {
+		return t, p.errorf(keyStart, "unknown filter %q", key+":")
+	}
+	t.Kind = kind
+
+	valueStart := p.pos
+
+	var value string
+	if p.pos < len(p.s) && p.s[p.pos] == '"' {
+		var err error
+		value, err = p.quoted()
+		if err != nil {
+			return t, err
+		}
+	} else {
+		value = p.token()
+	}
+
+	if strings.TrimSpace(value) == "" {
+		return t, p.errorf(keyStart, "missing value for %q", key+":")
+	}
+
+	switch kind {
+	case Before, After:
+		date, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			return t, p.errorf(valueStart, "%q must be a date like 2024-01-31", key+":")
+		}
+		t.Date = date
+	case Expires:
+		within, later, err := parseExpires(value)
+		if err != nil {
+			return t, p.errorf(valueStart, "%q must be a duration like <7d or >12h", key+":")
+		}
+		t.Within, t.Later = within, later
+	default:
+		t.Value = value
+	}
+
+	return t, nil
+}
+
+// Consume "key:" when the current token starts with a run of letters
+// followed by a colon.
+func (p *parser) filterKey() (string, bool) {
+	i := p.pos
+	for i < len(p.s) && ('a' <= p.s[i] && p.s[i] <= 'z' || 'A' <= p.s[i] && p.s[i] <= 'Z') {
+		i++
+	}
+
+	if i == p.pos || i >= len(p.s) || p.s[i] != ':' {
+		return "", false
+	}
+
+	key := p.s[p.pos:i]
+	p.pos = i + 1
+	return key, true
+}
+
+const maxExpires = 100 * 365 * 24 * time.Hour
+
+var units = []struct {
+	suffix string
+	size   time.Duration
+}{
+	{"w", 7 * 24 * time.Hour},
+	{"d", 24 * time.Hour},
+	{"h", time.Hour},
+	{"m", time.Minute},
+}
+
+// Parse an optional "<" or ">" followed by a whole number of minutes,
+// hours, days or weeks. Without an operator "<" is assumed.
+func parseExpires(s string) (time.Duration, bool, error) {
+	later := false
+
+	switch {
+	case strings.HasPrefix(s, "<"):
+		s = s[1:]
+	case strings.HasPrefix(s, ">"):
+		s = s[1:]
+		later = true
+	}
+
+	for _, u := range units {
+		digits, ok := strings.CutSuffix(s, u.suffix)
+		if !ok {
+			continue
+		}
+
+		// Limit the amount to roughly 100 years so the result can't overflow.
+		n, err := strconv.Atoi(digits)
+		if err != nil || n < 1 || digits[0] == '+' || time.Duration(n) > maxExpires/u.size {
+			return 0, false, fmt.Errorf("invalid duration %q", s)
+		}
+
+		return time.Duration(n) * u.size, later, nil
+	}
+
+	return 0, false, fmt.Errorf("invalid duration %q", s)
+}
+
+// Format a duration using the largest unit that divides it exactly.
+func formatDuration(d time.Duration) string {
+	for _, u := range units {
+		if d%u.size == 0 {
+			return strconv.FormatInt(int64(d/u.size), 10) + u.suffix
+		}
+	}
+	return strconv.FormatInt(int64(d/time.Minute), 10) + "m"
+}
@@ -0,0 +1,207 @@
+package query
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"snippetbox.davc.io/internal/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []Term
+	}{
+		{
+			name:  "Empty",
+			query: "   ",
+			want:  []Term{},
+		},
+		{
+			name:  "Words",
+			query: "old  pond",
+			want: []Term{
+				{Kind: Word, Value: "old"},
+				{Kind: Word, Value: "pond"},
+			},
+		},
+		{
+			name:  "Phrase",
+			query: `"old silent pond"`,
+			want:  []Term{{Kind: Phrase, Value: "old silent pond"}},
+		},
+		{
+			name:  "Negated",
+			query: `-frog -"silent pond" -tag:go`,
+			want: []Term{
+				{Kind: Word, Value: "frog", Negated: true},
+				{Kind: Phrase, Value: "silent pond", Negated: true},
+				{Kind: Tag, Value: "go", Negated: true},
+			},
+		},
+		{
+			name:  "Filters",
+			query: `tag:go Author:"Alice Jones" lang:sql`,
+			want: []Term{
+				{Kind: Tag, Value: "go"},
+				{Kind: Author, Value: "Alice Jones"},
+				{Kind: Lang, Value: "sql"},
+			},
+		},
+		{
+			name:  "Dates",
+			query: "before:2024-01-01 after:2023-06-30",
+			want: []Term{
+				{Kind: Before, Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+				{Kind: After, Date: time.Date(2023, 6, 30, 0, 0, 0, 0, time.UTC)},
+			},
+		},
+		{
+			name:  "Expires",
+			query: "expires:<7d expires:>12h expires:2w",
+			want: []Term{
+				{Kind: Expires, Within: 7 * 24 * time.Hour},
+				{Kind: Expires, Within: 12 * time.Hour, Later: true},
+				{Kind: Expires, Within: 14 * 24 * time.Hour},
+			},
+		},
+		{
+			name:  "Colons in words",
+			query: ":foo 1a:b",
+			want: []Term{
+				{Kind: Word, Value: ":foo"},
+				{Kind: Word, Value: "1a:b"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := Parse(tt.query)
+
+			assert.NilError(t, err)
+			if err == nil && !reflect.DeepEqual(q.Terms, tt.want) {
+				t.Errorf("got: %+v; want: %+v", q.Terms, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		wantPos int
+		wantMsg string
+	}{
+		{
+			name:    "Unknown filter",
+			query:   "pond auther:alice",
+			wantPos: 5,
+			wantMsg: `unknown filter "auther:"`,
+		},
+		{
+			name:    "Missing value",
+			query:   "tag: go",
+			wantPos: 0,
+			wantMsg: `missing value for "tag:"`,
+		},
+		{
+			name:    "Unterminated phrase",
+			query:   `pond "silent`,
+			wantPos: 5,
+			wantMsg: "missing closing quote",
+		},
+		{
+			name:    "Empty phrase",
+			query:   `"  "`,
+			wantPos: 0,
+			wantMsg: "empty phrase",
+		},
+		{
+			name:    "Lone minus",
+			query:   "pond - frog",
+			wantPos: 5,
+			wantMsg: `expected a term after "-"`,
+		},
+		{
+			name:    "Double minus",
+			query:   "--frog",
+			wantPos: 1,
+			wantMsg: `unexpected "-"`,
+		},
+		{
+			name:    "Invalid date",
+			query:   "before:2024-13-01",
+			wantPos: 7,
+			wantMsg: `"before:" must be a date like 2024-01-31`,
+		},
+		{
+			name:    "Invalid duration",
+			query:   "expires:<7y",
+			wantPos: 8,
+			wantMsg: `"expires:" must be a duration like <7d or >12h`,
+		},
+		{
+			name:    "Too many terms",
+			query:   "a b c d e f g h i j k l m n o p q r s t u",
+			wantPos: 40,
+			wantMsg: "too many terms (at most 20)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.query)
+
+			var syntaxError *SyntaxError
+			if !errors.As(err, &syntaxError) {
+				t.Fatalf("got: %v; want: *SyntaxError", err)
+			}
+
+			assert.Equal(t, syntaxError.Pos, tt.wantPos)
+			assert.Equal(t, syntaxError.Msg, tt.wantMsg)
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	q, err := Parse(`-Tag:go author:"Alice Jones"   "a phrase" expires:>168h before:2024-01-01`)
+	assert.NilError(t, err)
+
+	assert.Equal(t, q.String(), `-tag:go author:"Alice Jones" "a phrase" expires:>1w before:2024-01-01`)
+}
+
+// Parsing must never panic, and formatting a parsed query must give a query
+// that parses to the same terms.
+func FuzzParse(f *testing.F) {
+	f.Add(`tag:go author:alice lang:sql before:2024-01-01 expires:<7d "exact phrase" -exclude`)
+	f.Add(`-"a b" after:2020-02-29 expires:>90m author:"x y"`)
+	f.Add(`:: a:b "" - -- " tag:"`)
+
+	f.Fuzz(func(t *testing.T, s string) {
+		q, err := Parse(s)
+		if err != nil {
+			var syntaxError *SyntaxError
+			if !errors.As(err, &syntaxError) {
+				t.Fatalf("got: %v; want: *SyntaxError", err)
+			}
+			if syntaxError.Pos < 0 || syntaxError.Pos > len(s) {
+				t.Fatalf("position %d out of range for %q", syntaxError.Pos, s)
+			}
+			return
+		}
+
+		again, err := Parse(q.String())
+		if err != nil {
+			t.Fatalf("reparsing %q (from %q): %v", q.String(), s, err)
+		}
+
+		if !reflect.DeepEqual(q, again) {
+			t.Fatalf("got: %+v; want: %+v (from %q)", again, q, s)
+		}
+	})
+}
@@ -5,4 +5,76 @@ for (var i = 0; i < navLinks.length; i++) {
 		link.classList.add("live");
 		break;
 	}
+}
+
+// Suggest existing tags for the last comma-separated entry of a tags field.
+var tagInputs = document.querySelectorAll("input[name='tags']");
+for (var i = 0; i < tagInputs.length; i++) {
+	tagInputs[i].addEventListener("input", suggestTags);
+}
+
+function suggestTags(event) {
+	var input = event.target;
+	var list = document.getElementById(input.getAttribute("list"));
+	var parts = input.value.split(",");
+	var prefix = parts.pop().trim().toLowerCase();
+
+	if (prefix == "") {
+		list.replaceChildren();
+		return;
+	}
+
+	fetch("/tags/suggest?prefix=" + encodeURIComponent(prefix))
+		.then(function (response) { return response.json(); })
+		.then(function (tags) {
+			var head = parts.map(function (p) { return p.trim(); }).filter(Boolean);
+			var options = tags.map(function (tag) {
+				var option = document.createElement("option");
+				option.value = head.concat(tag).join(", ");
+				return option;
+			});
+			list.replaceChildren.apply(list, options);
+		});
+}
+
+// Add and remove the files after the first on a snippet form, numbering
+// their fields from zero in order.
+var fileLists = document.querySelectorAll("div.files");
+for (var i = 0; i < fileLists.length; i++) {
+	fileLists[i].addEventListener("click", editFiles);
+}
+
+function editFiles(event) {
+	var list = event.currentTarget;
+	var button = event.target;
+
+	if (button.classList.contains("add-file")) {
+		var template = list.querySelector("template.file-template");
+		list.querySelector("div.file-rows").appendChild(template.content.firstElementChild.cloneNode(true));
+	} else if (button.classList.contains("remove-file")) {
+		button.closest("div.file").remove();
+	} else {
+		return;
+	}
+
+	var rows = list.querySelectorAll("div.file-rows div.file");
+	for (var i = 0; i < rows.length; i++) {
+		var fields = rows[i].querySelectorAll("[data-field]");
+		for (var j = 0; j < fields.length; j++) {
+			fields[j].name = "files[" + i + "]." + fields[j].getAttribute("data-field");
+		}
+	}
+}
+
+// Fill in the line a comment is about from the line number last clicked.
+var commentLine = document.querySelector("form.comment-form input[name='line']");
+if (commentLine) {
+	window.addEventListener("hashchange", pickCommentLine);
+}
+
+function pickCommentLine() {
+	var anchor = window.location.hash.slice(1);
+	if (/^(file-[0-9]+-)?L[0-9]+$/.test(anchor)) {
+		commentLine.value = anchor;
+	}
 }
\ No newline at end of file